@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/warpfork/shareworks-munger/pkg/shareworks"
+)
+
+// With --audit-columns, values the statement gives that we can also work out from other values in the same event are checked,
+// and where the two disagree by more than --audit-tolerance, both go in the output:
+// the statement's value stays where it was, and the worked-out value and the difference go in columns of their own
+// ("Gross Proceeds (computed)" and "Gross Proceeds (delta)").  The delta is the statement's value minus the computed one.
+// Then whoever's doing the taxes can decide which to believe.
+//
+// Only things that can be worked out unambiguously are checked.
+// Values in different currencies are skipped: there are no exchange rates in a statement, so there's nothing to convert with.
+
+// auditRule is one value that can be checked.
+type auditRule struct {
+	column    string
+	eventType string
+	// compute works out what the column should be, and in what currency.  It's not ok if the event doesn't have what it needs.
+	compute func(ent map[string]string) (amount float64, currency string, ok bool)
+}
+
+// auditRules are the values that can be checked.  Which columns they're in depends on the breakdown prefixes.
+func auditRules(prefixes shareworks.BreakdownPrefixes) []auditRule {
+	gross := prefixes.Column("Sale Breakdown", "Gross Proceeds", "Sell")
+	return []auditRule{
+		// A sale's gross proceeds should be the shares sold times the price they sold at.
+		{gross, "Sell", func(ent map[string]string) (float64, string, bool) {
+			shares, err := parseShareCount(ent, ent["stocks report"])
+			if err != nil {
+				return 0, "", false
+			}
+			price, currency, err := shareworks.ParseEntryMoney(ent, ent["price per unit"])
+			if err != nil {
+				return 0, "", false
+			}
+			return shares * price, currency, true
+		}},
+		// And what's left of them after the fees and commissions should be the net.
+		{prefixes.Total("Sale Breakdown", "Sale Breakdown Total"), "Sell", func(ent map[string]string) (float64, string, bool) {
+			amount, currency, err := shareworks.ParseEntryMoney(ent, ent[gross])
+			if err != nil {
+				return 0, "", false
+			}
+			for key, text := range ent {
+				if !isFeeColumn(key) {
+					continue
+				}
+				fee, feeCurrency, err := shareworks.ParseEntryMoney(ent, text)
+				if err != nil || feeCurrency != currency {
+					return 0, "", false
+				}
+				amount -= fee
+			}
+			return amount, currency, true
+		}},
+	}
+}
+
+// isFeeColumn reports whether a column is a fee or commission taken out of a sale's proceeds (and not one of our own audit columns about one).
+func isFeeColumn(column string) bool {
+	lower := strings.ToLower(column)
+	if strings.HasSuffix(lower, " (computed)") || strings.HasSuffix(lower, " (delta)") {
+		return false
+	}
+	return strings.Contains(lower, "fee") || strings.Contains(lower, "commission")
+}
+
+// auditValues adds the audit columns to any event where the statement and the computed value disagree by more than tolerance.
+func auditValues(columns *[]string, entries []map[string]string, prefixes shareworks.BreakdownPrefixes, tolerance float64) {
+	for _, rule := range auditRules(prefixes) {
+		for _, ent := range entries {
+			if ent["Type"] != rule.eventType {
+				continue
+			}
+			text, ok := ent[rule.column]
+			if !ok {
+				continue
+			}
+			stated, statedCurrency, err := shareworks.ParseEntryMoney(ent, text)
+			if err != nil {
+				continue
+			}
+			computed, currency, ok := rule.compute(ent)
+			if !ok {
+				continue
+			}
+			if statedCurrency != currency {
+				tracef("audit: skipping %q of event %q: it's in %q, but what it's computed from is in %q", rule.column, ent["Event"], statedCurrency, currency)
+				continue
+			}
+			delta := stated - computed
+			if math.Abs(delta) <= tolerance {
+				continue
+			}
+			tracef("audit: %q of event %q is %s, but works out to %s", rule.column, ent["Event"], text, formatAuditAmount(computed))
+			shareworks.Accumulate(columns, ent, fmt.Sprintf("%s (computed)", rule.column), formatAuditAmount(computed))
+			shareworks.Accumulate(columns, ent, fmt.Sprintf("%s (delta)", rule.column), formatAuditAmount(delta))
+		}
+	}
+}
+
+// formatAuditAmount writes an amount without any float noise, but without rounding away the fractions of a cent that might be the whole point.
+func formatAuditAmount(f float64) string {
+	return strconv.FormatFloat(math.Round(f*1e6)/1e6, 'f', -1, 64)
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The beancount output is a journal with a transaction for each release and withdrawal; see journal.go for how the accounts are picked.
+//
+// A release is income: the shares go into the schedule's account at the release price, and the same value comes out of the income account.
+// A withdrawal is a sale: the shares come out of the schedule's account at the sale price, the net proceeds go to cash and the difference to fees,
+// and the gains account gets whatever balances it (beancount works that out from what the shares cost).
+// Anything else (other buys, transfers, dividends, things the fallback parser found) goes in as a comment, to be entered by hand.
+//
+// The accounts it uses are opened at the top, on the date of the first transaction, so the file checks on its own.
+// For the same reason, a sale's shares have to have come into the account somewhere in the file.
+// If the release they came from was left out (by --from, --schedule, or --split-by), they come in from the opening balances account on its date instead, at its price;
+// and shares from before the statement starts, with no release at all, come in the same way right before the sale, at the sale price.
+
+func emitBeancount(wr io.Writer, source string, cfg journalConfig, names nameProfile, history, entries []map[string]string) error {
+	var body strings.Builder
+	accounts := map[string]string{} // account -> booking method, if it holds shares.
+	var first time.Time
+	warned := map[string]bool{}
+	sorted, written := journalEntries(names, history, entries)
+	// Which shares the sales being written take from releases that aren't.
+	opening := map[int]float64{}
+	fromReleases := map[int]float64{}
+	for _, m := range followShares(sorted) {
+		if !written[m.to] {
+			continue
+		}
+		fromReleases[m.to] += m.shares
+		if !written[m.from] {
+			opening[m.from] += m.shares
+		}
+	}
+	for i, ent := range sorted {
+		title := ent["Event"]
+		if !written[i] && opening[i] == 0 {
+			continue
+		}
+		ev, whyNot := readJournalEvent(cfg, ent)
+		if whyNot == "" && ev.kind == "purchase" && written[i] {
+			whyNot = "enter it by hand" // there's no account in the config for the contributions that paid for it.
+		}
+		if whyNot != "" {
+			fmt.Fprintf(&body, "; Not in the journal, %s: %s (%s)\n\n", whyNot, title, ent["Type"])
+			continue
+		}
+		sched := cfg.schedule(ent["Distribution Schedule"], warned)
+		if first.IsZero() || ev.date.Before(first) {
+			first = ev.date
+		}
+
+		posting := func(account, amount string) {
+			if _, ok := accounts[account]; !ok {
+				accounts[account] = ""
+			}
+			if amount == "" {
+				fmt.Fprintf(&body, "  %s\n", account)
+				return
+			}
+			fmt.Fprintf(&body, "  %-40s %s\n", account, amount)
+		}
+		accounts[sched.Account] = "FIFO"
+		openingLot := func(title string, shares, price float64, currency string) {
+			fmt.Fprintf(&body, "%s * %s\n", ev.date.Format(isoDateLayout), strconv.Quote(title))
+			fmt.Fprintf(&body, "  source: %s\n", strconv.Quote(source))
+			posting(sched.Account, fmt.Sprintf("%s %s {%s %s}", formatShareCount(shares), sched.Commodity, formatJournalMoney(price), currency))
+			posting(cfg.Opening, "")
+			body.WriteString("\n")
+		}
+		if !written[i] {
+			// A release that isn't in the journal, but some of whose shares are sold in it.
+			openingLot("Opening balance: "+title, opening[i], ev.price, ev.currency)
+			continue
+		}
+		if remaining := ev.shares - fromReleases[i]; ev.kind == "sale" && remaining > shareEpsilon {
+			openingLot(fmt.Sprintf("Opening balance: shares sold in %s, with no release seen", title), remaining, ev.price, ev.currency)
+		}
+
+		fmt.Fprintf(&body, "%s * %s\n", ev.date.Format(isoDateLayout), strconv.Quote(title))
+		fmt.Fprintf(&body, "  source: %s\n", strconv.Quote(source))
+		switch ev.kind {
+		case "release":
+			posting(sched.Account, fmt.Sprintf("%s %s {%s %s}", formatShareCount(ev.shares), sched.Commodity, formatJournalMoney(ev.price), ev.currency))
+			posting(cfg.Income, fmt.Sprintf("%s %s", formatJournalMoney(-ev.shares*ev.price), ev.currency))
+		case "sale":
+			posting(sched.Account, fmt.Sprintf("%s %s {} @ %s %s", formatShareCount(-ev.shares), sched.Commodity, formatJournalMoney(ev.price), ev.currency))
+			posting(cfg.Cash, fmt.Sprintf("%s %s", formatJournalMoney(ev.net), ev.grossCurrency))
+			if fees := ev.fees(); fees != 0 {
+				posting(cfg.Fees, fmt.Sprintf("%s %s", formatJournalMoney(fees), ev.grossCurrency))
+			}
+			posting(cfg.Gains, "")
+		}
+		body.WriteString("\n")
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "; From %s, by shareworks-munger %s.\n\n", source, toolVersion())
+	if len(accounts) > 0 {
+		opened := make([]string, 0, len(accounts))
+		for account := range accounts {
+			opened = append(opened, account)
+		}
+		sort.Strings(opened)
+		for _, account := range opened {
+			if booking := accounts[account]; booking != "" {
+				fmt.Fprintf(&sb, "%s open %s %q\n", first.Format(isoDateLayout), account, booking)
+			} else {
+				fmt.Fprintf(&sb, "%s open %s\n", first.Format(isoDateLayout), account)
+			}
+		}
+		sb.WriteString("\n")
+	}
+	sb.WriteString(body.String())
+	if _, err := io.WriteString(wr, sb.String()); err != nil {
+		return fmt.Errorf("error while emitting beancount: %w", err)
+	}
+	return nil
+}
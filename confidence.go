@@ -0,0 +1,25 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/warpfork/shareworks-munger/pkg/shareworks"
+)
+
+// The "Confidence" column itself is filled in by the parser (see pkg/shareworks); `--review` lists every event that isn't "high".
+
+// reviewConfidence lists every event that isn't high confidence.
+func reviewConfidence(filename string, entries []map[string]string) {
+	count := 0
+	for _, ent := range entries {
+		level, ok := ent["Confidence"]
+		if !ok || level == shareworks.ConfidenceHigh {
+			continue
+		}
+		count++
+		infof("%q: review: event %q (settlement date %q) has %s confidence: %s", filename, ent["Event"], ent["Settlement Date:"], level, strings.TrimSpace(ent["Confidence Note"]))
+	}
+	if count == 0 {
+		infof("%q: review: all events parsed with high confidence.", filename)
+	}
+}
@@ -0,0 +1,11 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+// launchedWithoutConsole is only a thing on Windows.  Everywhere else, people run us from a terminal.
+func launchedWithoutConsole() bool {
+	return false
+}
+
+func notify(title, message string) {}
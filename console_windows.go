@@ -0,0 +1,35 @@
+//go:build windows
+// +build windows
+
+package main
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+var (
+	kernel32                  = syscall.NewLazyDLL("kernel32.dll")
+	procGetConsoleProcessList = kernel32.NewProc("GetConsoleProcessList")
+	user32                    = syscall.NewLazyDLL("user32.dll")
+	procMessageBoxW           = user32.NewProc("MessageBoxW")
+)
+
+// launchedWithoutConsole reports whether we're the only process attached to our console.
+// That's what it looks like when someone drags a file onto the exe (or double-clicks a file associated with it) in Explorer:
+// Windows makes a fresh console window just for us, and it'll vanish the instant we exit,
+// taking all our output with it.
+// When run from a terminal, the shell is attached to the console too, so there's more than one process.
+func launchedWithoutConsole() bool {
+	var pids [2]uint32
+	n, _, _ := procGetConsoleProcessList.Call(uintptr(unsafe.Pointer(&pids[0])), uintptr(len(pids)))
+	return n == 1
+}
+
+// notify pops up a message box, so there's something for the user to see once we're done.
+func notify(title, message string) {
+	const mbIconInformation = 0x40
+	t, _ := syscall.UTF16PtrFromString(title)
+	m, _ := syscall.UTF16PtrFromString(message)
+	procMessageBoxW.Call(0, uintptr(unsafe.Pointer(m)), uintptr(unsafe.Pointer(t)), mbIconInformation)
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"strings"
+	"time"
+
+	"github.com/warpfork/shareworks-munger/pkg/shareworks"
+)
+
+// The statements write dates like "02-Jan-2006", which nothing downstream of a csv parses reliably:
+// some tools don't recognize it at all, and spreadsheets guess at it using the system locale.
+// With --dates=iso, every column of statement dates is rewritten as "2006-01-02" on the way out.
+//
+// As with --numeric, whole columns are converted or not: only columns where every value is a statement date.
+// Columns a --transform with "date:" has already reformatted are left alone, even if what it wrote could be read as a date again.
+
+const isoDateLayout = "2006-01-02"
+
+// Dates are read by trying each of the layouts in dateLayouts, in order; see shareworks.DateLayouts.
+// --date-layout replaces the list, for statements that write their dates some other way.
+var dateLayouts = shareworks.DefaultDateLayouts
+
+// parseDate reads a date with whichever of dateLayouts fits it.
+func parseDate(s string) (time.Time, error) {
+	return dateLayouts.Parse(s)
+}
+
+// dateLayoutList implements flag.Value, so --date-layout can be given several times.
+type dateLayoutList shareworks.DateLayouts
+
+func (l *dateLayoutList) String() string {
+	return strings.Join(*l, "; ")
+}
+
+func (l *dateLayoutList) Set(s string) error {
+	if err := shareworks.CheckDateLayout(s); err != nil {
+		return err
+	}
+	*l = append(*l, s)
+	return nil
+}
+
+// isoDates returns copies of the entries, with every column of statement dates rewritten in ISO 8601 form, except the columns in skip.
+// The originals are left alone.
+func isoDates(columnOrder []string, entries []map[string]string, skip map[string]bool) []map[string]string {
+	result := make([]map[string]string, len(entries))
+	for i, ent := range entries {
+		row := make(map[string]string, len(ent))
+		for k, v := range ent {
+			row[k] = v
+		}
+		result[i] = row
+	}
+	for _, col := range columnOrder {
+		if skip[col] {
+			continue
+		}
+		converted, ok := parseDateColumn(col, entries)
+		if !ok {
+			continue
+		}
+		for i, row := range result {
+			if _, present := row[col]; present {
+				row[col] = converted[i]
+			}
+		}
+	}
+	return result
+}
+
+// parseDateColumn reads every value in a column as a date, and writes it in ISO form.
+// It's not ok if any of them can't be read, or if the column has no values at all.
+func parseDateColumn(col string, entries []map[string]string) ([]string, bool) {
+	converted := make([]string, len(entries))
+	seen := false
+	for i, ent := range entries {
+		value := ent[col]
+		if value == "" {
+			continue
+		}
+		date, err := parseDate(value)
+		if err != nil {
+			return nil, false
+		}
+		converted[i] = date.Format(isoDateLayout)
+		seen = true
+	}
+	return converted, seen
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/warpfork/shareworks-munger/pkg/shareworks"
+)
+
+// For --debug-json: what the parser saw in each input, before any of it was normalized or checked.
+// It's one json array with an object per input file, each holding that file's events in the order they were in the statement.
+// Handy for writing fixtures, and for bug reports where the output looks wrong and the question is whether the statement was.
+
+// debugSource is the debug events from one input file.
+type debugSource struct {
+	Source string                  `json:"source"`
+	Events []shareworks.DebugEvent `json:"events"`
+}
+
+var debugSources []debugSource
+
+// noteDebugEvents records the debug events from one input file.
+func noteDebugEvents(source string, events []shareworks.DebugEvent) {
+	if events == nil {
+		events = []shareworks.DebugEvent{} // so it's "[]" in the json, and not "null".
+	}
+	debugSources = append(debugSources, debugSource{source, events})
+}
+
+// writeDebugJson writes everything noted so far to filename.
+func writeDebugJson(filename string) error {
+	sources := debugSources
+	if sources == nil {
+		sources = []debugSource{}
+	}
+	bs, err := json.MarshalIndent(sources, "", "\t")
+	if err != nil {
+		return fmt.Errorf("failed to serialize debug json: %w", err)
+	}
+	if err := ioutil.WriteFile(filename, append(bs, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write debug json file %q: %w", filename, err)
+	}
+	return nil
+}
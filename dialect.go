@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// csvDialect describes the little details of csv that differ depending on what's going to open it.
+//
+// Excel in particular is picky: when you double-click a csv, it uses the *system locale* to decide
+// what the delimiter is and what the decimal separator is.  So in much of Europe,
+// a perfectly normal comma-delimited csv opens as one giant column.  Wonderful.
+type csvDialect struct {
+	delimiter    rune
+	decimalComma bool   // write "42,50" instead of "42.50" (and "1.234,56" instead of "1,234.56").
+	bom          bool   // start with a UTF-8 byte order mark, which is how Excel knows the file isn't in some ancient codepage.
+	blank        string // what to write for a missing value.  Some importers choke on empty cells.
+}
+
+var defaultCsvDialect = csvDialect{delimiter: ','}
+
+// excelLocales are the presets for --excel-locale.
+var excelLocales = map[string]csvDialect{
+	"us": {delimiter: ',', bom: true},
+	"uk": {delimiter: ',', bom: true},
+	"de": {delimiter: ';', decimalComma: true, bom: true},
+	"fr": {delimiter: ';', decimalComma: true, bom: true},
+	"es": {delimiter: ';', decimalComma: true, bom: true},
+	"it": {delimiter: ';', decimalComma: true, bom: true},
+	"nl": {delimiter: ';', decimalComma: true, bom: true},
+	"pt": {delimiter: ';', decimalComma: true, bom: true},
+	"ch": {delimiter: ';', bom: true},
+}
+
+func excelLocaleDialect(locale string) (csvDialect, error) {
+	d, ok := excelLocales[strings.ToLower(locale)]
+	if !ok {
+		var known []string
+		for k := range excelLocales {
+			known = append(known, k)
+		}
+		sort.Strings(known)
+		return csvDialect{}, fmt.Errorf("unknown excel locale %q -- known locales are: %s", locale, strings.Join(known, ", "))
+	}
+	return d, nil
+}
+
+// numericValuePattern matches values that are just a number, maybe with a currency on it.
+// Those are the only ones we'll swap decimal separators in; anything else (dates, names...) is left alone.
+var numericValuePattern = regexp.MustCompile(`^[-(]?(US\$|C\$|[$€£])?\s*-?[\d.,]+\)?(\s*[A-Z]{3})?$`)
+
+// toDecimalComma swaps the decimal point and thousands separators of a numeric value.
+func toDecimalComma(value string) string {
+	if !numericValuePattern.MatchString(value) {
+		return value
+	}
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '.':
+			return ','
+		case ',':
+			return '.'
+		default:
+			return r
+		}
+	}, value)
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+
+	"github.com/warpfork/shareworks-munger/pkg/shareworks"
+)
+
+// readCanonicalCsv reads a csv file that's in the same shape as what emitCsv produces:
+// a header row of column names, and then one row per event.
+// It returns the same columns-and-entries pair that munge does, so the results can be mixed together.
+//
+// This is how we take in events that happened outside of Shareworks
+// (e.g. shares that got transferred to a broker and then sold there), which you'll have to write up by hand.
+// Columns that were renamed with the name profile are turned back into their built-in names.
+// The number format is worked out from the values, the same way it is for html, unless format says what it is.
+func readCanonicalCsv(filename string, names nameProfile, format shareworks.NumberFormat) (columns []string, entries []map[string]string, err error) {
+	f, err := openInput(filename)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open csv file %q: %w", filename, err)
+	}
+	defer f.Close()
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read csv file %q: %w", filename, err)
+	}
+	if len(records) < 1 {
+		return nil, nil, fmt.Errorf("csv file %q is empty -- it needs at least a header row", filename)
+	}
+	header := records[0]
+	for _, record := range records[1:] {
+		row := map[string]string{}
+		for i, value := range record {
+			// Blank cells are the same as absent, just like in what we emit.
+			if value == "" {
+				continue
+			}
+			shareworks.Accumulate(&columns, row, names.unrename(header[i]), value)
+		}
+		entries = append(entries, row)
+	}
+	if format == "" {
+		var values []string
+		for _, record := range records[1:] {
+			values = append(values, record...)
+		}
+		format = shareworks.DetectNumberFormat("", values)
+	}
+	if format == shareworks.DecimalComma {
+		for _, ent := range entries {
+			ent[shareworks.NumberFormatKey] = string(shareworks.DecimalComma)
+		}
+	}
+	return columns, entries, nil
+}
+
+// mergeEntries folds a second set of columns and entries into the first.
+// Extra entries that are exactly identical to one we've already got are dropped,
+// so it doesn't hurt if a manually-entered event later shows up in the Shareworks data too.
+// The result is re-sorted, so the extra events land in the right place chronologically.
+func mergeEntries(columns []string, entries []map[string]string, extraColumns []string, extraEntries []map[string]string) ([]string, []map[string]string) {
+	for _, col := range extraColumns {
+		found := false
+		for _, existing := range columns {
+			if existing == col {
+				found = true
+				break
+			}
+		}
+		if !found {
+			columns = append(columns, col)
+		}
+	}
+	for _, extra := range extraEntries {
+		duplicate := false
+		for _, ent := range entries {
+			if sameEntry(ent, extra) {
+				duplicate = true
+				break
+			}
+		}
+		if !duplicate {
+			// Copy, because the same extra entries may get merged into several outputs.
+			row := make(map[string]string, len(extra))
+			for k, v := range extra {
+				row[k] = v
+			}
+			entries = append(entries, row)
+		}
+	}
+	shareworks.SortEntries(entries, dateLayouts, nil)
+	return columns, entries
+}
+
+// sameEntry reports whether two entries have all the same values.
+// The confidence columns, raw html, source file, and document order don't count; they're about how we parsed it, not about the event.
+func sameEntry(a, b map[string]string) bool {
+	for _, pair := range [][2]map[string]string{{a, b}, {b, a}} {
+		for k, v := range pair[0] {
+			if k == "Confidence" || k == "Confidence Note" || k == shareworks.RawHTMLKey || k == sourceFileColumn || k == documentOrderKey || k == shareworks.NumberFormatKey {
+				continue
+			}
+			if v2, ok := pair[1][k]; !ok || v2 != v {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// --from and --to keep just the events in a date range, like one tax year out of a statement that covers eighteen months.
+// The range is inclusive at both ends, and goes by settlement date, unless --date-column says otherwise.
+//
+// The filtering (this, and --schedule, below) happens after the share balance checks, which need to see everything that came before the range to make sense of it.
+
+type dateFilter struct {
+	column   string
+	from, to time.Time // zero for no limit.
+}
+
+// parseRangeDate parses a --from or --to date: either "2006-01-02", or the way the statements write them, "02-Jan-2006".
+func parseRangeDate(s string) (time.Time, error) {
+	if t, err := time.Parse(isoDateLayout, s); err == nil {
+		return t, nil
+	}
+	if t, err := parseDate(s); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("%q should be a date like 2006-01-02", s)
+}
+
+func (r dateFilter) active() bool {
+	return !r.from.IsZero() || !r.to.IsZero()
+}
+
+// apply returns just the entries in the range.
+// Entries without a date in the column can't be said to be in the range, so they're left out too.
+func (r dateFilter) apply(entries []map[string]string) []map[string]string {
+	var result []map[string]string
+	for _, ent := range entries {
+		date, err := parseDate(ent[r.column])
+		if err != nil {
+			tracef("date range: leaving out event %q: no usable %q", ent["Event"], r.column)
+			continue
+		}
+		if (!r.from.IsZero() && date.Before(r.from)) || (!r.to.IsZero() && date.After(r.to)) {
+			continue
+		}
+		result = append(result, ent)
+	}
+	return result
+}
+
+// --schedule keeps just the events from some distribution schedules, like the RSUs but not the phantom units.
+// Each pattern is a schedule name, or a glob like "*RSU*"; it can be given several times, and an event is kept if any of them match.
+// Matching ignores case, since the headings aren't always capitalized the same way from one statement to the next.
+// Unlike a glob for file paths, "*" matches anything at all, slashes included: schedules are called things like "ESPP 2021/2022".
+
+// schedulePatterns implements flag.Value, so --schedule can be given several times.
+type schedulePatterns []string
+
+func (l *schedulePatterns) String() string {
+	return strings.Join(*l, ", ")
+}
+
+func (l *schedulePatterns) Set(s string) error {
+	if _, err := scheduleGlob(s); err != nil {
+		return fmt.Errorf("schedule pattern %q isn't a valid glob: %w", s, err)
+	}
+	*l = append(*l, s)
+	return nil
+}
+
+// apply returns just the entries from matching distribution schedules.
+func (l schedulePatterns) apply(entries []map[string]string) []map[string]string {
+	var globs []*regexp.Regexp
+	for _, pattern := range l {
+		glob, _ := scheduleGlob(pattern) // Already checked by Set.
+		globs = append(globs, glob)
+	}
+	var result []map[string]string
+	for _, ent := range entries {
+		for _, glob := range globs {
+			if glob.MatchString(ent["Distribution Schedule"]) {
+				result = append(result, ent)
+				break
+			}
+		}
+	}
+	return result
+}
+
+// scheduleGlob turns a --schedule glob into a regexp that matches the whole of a schedule name, ignoring case.
+// "*" is any run of characters, "?" is any one character, "[...]" is a character class (or "[!...]", not in it), and "\" escapes the next character.
+func scheduleGlob(pattern string) (*regexp.Regexp, error) {
+	var re strings.Builder
+	re.WriteString("(?is)^")
+	runes := []rune(pattern)
+	for i := 0; i < len(runes); i++ {
+		switch c := runes[i]; c {
+		case '*':
+			re.WriteString(".*")
+		case '?':
+			re.WriteString(".")
+		case '\\':
+			i++
+			if i == len(runes) {
+				return nil, fmt.Errorf("nothing after the last \"\\\"")
+			}
+			re.WriteString(regexp.QuoteMeta(string(runes[i])))
+		case '[':
+			end := i + 1
+			if end < len(runes) && runes[end] == '!' {
+				end++
+			}
+			if end < len(runes) && runes[end] == ']' {
+				end++
+			}
+			for end < len(runes) && runes[end] != ']' {
+				end++
+			}
+			if end == len(runes) {
+				return nil, fmt.Errorf("a \"[\" is never closed")
+			}
+			class := string(runes[i+1 : end])
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			re.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i = end
+		default:
+			re.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	re.WriteString("$")
+	return regexp.Compile(re.String())
+}
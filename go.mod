@@ -2,9 +2,9 @@ module github.com/warpfork/shareworks-munger
 
 go 1.17
 
-require github.com/PuerkitoBio/goquery v1.8.0
-
 require (
-	github.com/andybalholm/cascadia v1.3.1 // indirect
-	golang.org/x/net v0.0.0-20210916014120-12bc252f5db8 // indirect
+	github.com/PuerkitoBio/goquery v1.8.0
+	golang.org/x/net v0.0.0-20210916014120-12bc252f5db8
 )
+
+require github.com/andybalholm/cascadia v1.3.1 // indirect
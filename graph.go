@@ -0,0 +1,168 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// The graph outputs (--format=dot for Graphviz, and --format=graph-json for anything else) show how the events connect:
+// which releases the shares in each sale or transfer came from.
+// That's handy for auditing a busy month, where several releases, sales to cover, and transfers all land at once.
+//
+// Each event is a node, and an edge goes from each release (or purchase) to each withdrawal that took shares from it, labelled with how many.
+// (See lots.go for how that's worked out.)  Same-day sales say so on their edge.
+// Shares sold to cover taxes never leave the release, so they're noted on the release's node rather than getting an edge.
+
+// eventKind says what sort of thing an event is, more finely than its Type does.
+func eventKind(ent map[string]string) string {
+	switch ent["Type"] {
+	case "Buy":
+		if strings.HasPrefix(ent["Event"], "Release") {
+			return "release"
+		}
+		return "purchase"
+	case "Sell":
+		// Withdrawals that were transferred out, rather than sold, have no trade and no proceeds.
+		if ent["Trade Date:"] != "" || ent["Gross Proceeds"] != "" {
+			return "sale"
+		}
+		return "transfer"
+	case "Dividend":
+		return "payout"
+	}
+	return "other"
+}
+
+// eventGraph is what --format=graph-json emits.
+type eventGraph struct {
+	Nodes []graphNode `json:"nodes"`
+	Edges []graphEdge `json:"edges"`
+}
+
+type graphNode struct {
+	ID             string `json:"id"`
+	Kind           string `json:"kind"` // see eventKind.
+	Event          string `json:"event"`
+	Schedule       string `json:"schedule"`
+	SettlementDate string `json:"settlement_date,omitempty"`
+	Shares         string `json:"shares,omitempty"`
+	SoldToCover    string `json:"sold_to_cover,omitempty"`
+}
+
+type graphEdge struct {
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Kind   string `json:"kind"` // "shares", or "same-day sale".
+	Shares string `json:"shares"`
+}
+
+// buildEventGraph works out the nodes and edges for some entries.
+// The entries can be in any order; they're followed in settlement date order.
+// names is so the normalized columns can be found, whatever they've been renamed to.
+func buildEventGraph(names nameProfile, entries []map[string]string) eventGraph {
+	sorted := chronological(names, entries)
+	var g eventGraph
+	for i, ent := range sorted {
+		g.Nodes = append(g.Nodes, graphNode{
+			ID:             "e" + strconv.Itoa(i+1),
+			Kind:           eventKind(ent),
+			Event:          ent["Event"],
+			Schedule:       ent["Distribution Schedule"],
+			SettlementDate: ent["Settlement Date:"],
+			Shares:         ent["stocks report"],
+			SoldToCover:    ent["Shares Sold to Cover"],
+		})
+	}
+	for _, m := range followShares(sorted) {
+		kind := "shares"
+		if m.sameDay {
+			kind = "same-day sale"
+		}
+		g.Edges = append(g.Edges, graphEdge{g.Nodes[m.from].ID, g.Nodes[m.to].ID, kind, formatShareCount(m.shares)})
+	}
+	return g
+}
+
+func emitGraphJson(wr io.Writer, names nameProfile, entries []map[string]string) error {
+	g := buildEventGraph(names, entries)
+	// Empty lists, not nulls, for graphs with nothing in them.
+	if g.Nodes == nil {
+		g.Nodes = []graphNode{}
+	}
+	if g.Edges == nil {
+		g.Edges = []graphEdge{}
+	}
+	bs, err := json.MarshalIndent(g, "", "\t")
+	if err != nil {
+		return fmt.Errorf("error while emitting graph json: %w", err)
+	}
+	if _, err := wr.Write(append(bs, '\n')); err != nil {
+		return fmt.Errorf("error while emitting graph json: %w", err)
+	}
+	return nil
+}
+
+// dotNodeStyles are how each kind of event is drawn: green for shares coming in, red for going out, blue for payouts, like the timeline.
+var dotNodeStyles = map[string]string{
+	"release":  `shape=box, color="#2a9d4a"`,
+	"purchase": `shape=box, color="#2a9d4a", style=dashed`,
+	"sale":     `shape=ellipse, color="#d1495b"`,
+	"transfer": `shape=ellipse, color="#d1495b", style=dashed`,
+	"payout":   `shape=diamond, color="#3a6ea5"`,
+	"other":    `shape=note, color="#888888"`,
+}
+
+func emitDot(wr io.Writer, source string, names nameProfile, entries []map[string]string) error {
+	g := buildEventGraph(names, entries)
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "// From %s, by shareworks-munger %s.\n", source, toolVersion())
+	sb.WriteString("digraph shareworks {\n")
+	sb.WriteString("\trankdir=LR;\n")
+	// Each distribution schedule gets a box of its own, in the order they first show up.
+	var schedules []string
+	bySchedule := map[string][]graphNode{}
+	for _, n := range g.Nodes {
+		if _, ok := bySchedule[n.Schedule]; !ok {
+			schedules = append(schedules, n.Schedule)
+		}
+		bySchedule[n.Schedule] = append(bySchedule[n.Schedule], n)
+	}
+	for i, schedule := range schedules {
+		fmt.Fprintf(&sb, "\tsubgraph cluster_%d {\n", i)
+		fmt.Fprintf(&sb, "\t\tlabel=%s;\n", dotQuote(schedule))
+		for _, n := range bySchedule[schedule] {
+			label := n.Event
+			if n.Shares != "" {
+				label += "\n" + n.Shares + " shares"
+			}
+			if n.SoldToCover != "" {
+				label += "\n(" + n.SoldToCover + " sold to cover)"
+			}
+			if n.SettlementDate != "" {
+				label += "\nsettled " + n.SettlementDate
+			}
+			fmt.Fprintf(&sb, "\t\t%s [label=%s, %s];\n", n.ID, dotQuote(label), dotNodeStyles[n.Kind])
+		}
+		sb.WriteString("\t}\n")
+	}
+	for _, e := range g.Edges {
+		if e.Kind == "same-day sale" {
+			fmt.Fprintf(&sb, "\t%s -> %s [label=%s, style=bold];\n", e.From, e.To, dotQuote(e.Shares+" (same-day sale)"))
+		} else {
+			fmt.Fprintf(&sb, "\t%s -> %s [label=%s];\n", e.From, e.To, dotQuote(e.Shares))
+		}
+	}
+	sb.WriteString("}\n")
+	if _, err := io.WriteString(wr, sb.String()); err != nil {
+		return fmt.Errorf("error while emitting dot: %w", err)
+	}
+	return nil
+}
+
+// dotQuote makes a string into a dot string literal.  Newlines become "\n", which dot draws as line breaks.
+func dotQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s) + `"`
+}
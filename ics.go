@@ -0,0 +1,100 @@
+package main
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// The ics output is an iCalendar file with an all-day event for each vest (release) date, trade date, and settlement date,
+// so you can import it into a calendar and see at a glance when the taxable things happened.
+//
+// Only things that are actually in the statement end up in it.
+// (Projecting future vests would need the grant schedules, and Shareworks doesn't put those in this export.)
+
+// icsDateColumns are the columns that become calendar events, and what to call them.
+var icsDateColumns = []struct {
+	column string
+	label  string
+}{
+	{"Release Date:", "Vest"},
+	{"Trade Date:", "Trade"},
+	{"Settlement Date:", "Settlement"},
+}
+
+func emitIcs(wr io.Writer, source string, modified time.Time, entries []map[string]string) error {
+	var sb strings.Builder
+	line := func(s string) {
+		sb.WriteString(foldIcsLine(s))
+		sb.WriteString("\r\n")
+	}
+	// The stamp is when the input was last changed, not when it was munged, so munging it again gives exactly the same file.
+	stamp := modified.UTC().Format("20060102T150405Z")
+	line("BEGIN:VCALENDAR")
+	line("VERSION:2.0")
+	line("PRODID:-//shareworks-munger//" + escapeIcsText(toolVersion()) + "//EN")
+	line("CALSCALE:GREGORIAN")
+	for _, ent := range entries {
+		for _, dc := range icsDateColumns {
+			date, err := parseDate(ent[dc.column])
+			if err != nil {
+				continue
+			}
+			// A uid has to stay the same across exports, so calendars update an event instead of duplicating it.
+			uid := fmt.Sprintf("%x@shareworks-munger", sha1.Sum([]byte(ent["Distribution Schedule"]+"\x00"+ent["Event"]+"\x00"+dc.column)))
+			line("BEGIN:VEVENT")
+			line("UID:" + uid)
+			line("DTSTAMP:" + stamp)
+			line("DTSTART;VALUE=DATE:" + date.Format("20060102"))
+			line("DTEND;VALUE=DATE:" + date.AddDate(0, 0, 1).Format("20060102"))
+			line("SUMMARY:" + escapeIcsText(dc.label+": "+ent["Event"]))
+			line("DESCRIPTION:" + escapeIcsText(icsDescription(source, ent)))
+			line("TRANSP:TRANSPARENT")
+			line("END:VEVENT")
+		}
+	}
+	line("END:VCALENDAR")
+	if _, err := io.WriteString(wr, sb.String()); err != nil {
+		return fmt.Errorf("error while emitting ics: %w", err)
+	}
+	return nil
+}
+
+func icsDescription(source string, ent map[string]string) string {
+	var parts []string
+	for _, kv := range []struct{ label, column string }{
+		{"Schedule", "Distribution Schedule"},
+		{"Shares", "stocks report"},
+		{"Price per unit", "price per unit"},
+		{"Settlement date", "Settlement Date:"},
+	} {
+		if v := ent[kv.column]; v != "" {
+			parts = append(parts, kv.label+": "+v)
+		}
+	}
+	parts = append(parts, "From: "+source)
+	return strings.Join(parts, "\n")
+}
+
+// escapeIcsText escapes a value for an iCalendar TEXT property.
+func escapeIcsText(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`).Replace(s)
+}
+
+// foldIcsLine splits lines longer than 75 bytes, as the spec insists, without splitting a utf-8 character.
+func foldIcsLine(s string) string {
+	var sb strings.Builder
+	width := 0
+	for _, r := range s {
+		n := len(string(r))
+		if width+n > 75 {
+			sb.WriteString("\r\n ")
+			width = 1
+		}
+		sb.WriteRune(r)
+		width += n
+	}
+	return sb.String()
+}
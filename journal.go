@@ -0,0 +1,239 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/warpfork/shareworks-munger/pkg/shareworks"
+)
+
+// The accounting journal outputs (--format=beancount and --format=ledger) turn releases and withdrawals into transactions,
+// so they can go straight into your books instead of being copied over from the csv by hand.
+//
+// What accounts to use, and what commodity each distribution schedule's shares are, comes from a json file given with --journal-config:
+//
+//	{
+//		"currency": "USD",
+//		"income": "Income:Employer:RSU",
+//		"cash": "Assets:Broker:Cash",
+//		"fees": "Expenses:Broker:Fees",
+//		"gains": "Income:CapitalGains",
+//		"opening": "Equity:Opening-Balances",
+//		"schedules": {
+//			"2021 RSU Plan": {"commodity": "ACME", "account": "Assets:Broker:ACME"}
+//		}
+//	}
+//
+// Everything in it is optional.  Schedules that aren't listed get an account named after them, and a commodity of "SHARES" (with a warning).
+
+// journalConfig is the contents of a --journal-config file.
+type journalConfig struct {
+	Currency  string                     `json:"currency"` // for amounts that are just "$", with no other say in which dollars.
+	Income    string                     `json:"income"`   // where the value of released shares comes from.
+	Cash      string                     `json:"cash"`     // where the proceeds of a sale go.
+	Fees      string                     `json:"fees"`     // where commissions and other fees on a sale go.
+	Gains     string                     `json:"gains"`    // what balances a sale: the gain (or loss) over what the shares cost.
+	Opening   string                     `json:"opening"`  // where shares come from that were had before the journal starts.
+	Schedules map[string]journalSchedule `json:"schedules"`
+
+	breakdownPrefixes shareworks.BreakdownPrefixes // from --breakdown-prefixes, which says what the proceeds columns are called.
+}
+
+// journalSchedule is what a distribution schedule's shares are called in the journal, and where they're kept.
+type journalSchedule struct {
+	Commodity string `json:"commodity"`
+	Account   string `json:"account"`
+}
+
+var defaultJournalConfig = journalConfig{
+	Currency: "USD",
+	Income:   "Income:Shareworks",
+	Cash:     "Assets:Shareworks:Cash",
+	Fees:     "Expenses:Shareworks:Fees",
+	Gains:    "Income:Shareworks:Gains",
+	Opening:  "Equity:Shareworks:Opening",
+}
+
+// defaultJournalCommodity is the commodity for shares from schedules the config doesn't mention.
+const defaultJournalCommodity = "SHARES"
+
+// readJournalConfig reads a --journal-config file.  Anything it leaves out is filled in from defaultJournalConfig.
+// An empty filename gets just the defaults.
+func readJournalConfig(filename string) (journalConfig, error) {
+	cfg := defaultJournalConfig
+	if filename == "" {
+		return cfg, nil
+	}
+	bs, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return cfg, fmt.Errorf("failed to read journal config %q: %w", filename, err)
+	}
+	var read journalConfig
+	if err := json.Unmarshal(bs, &read); err != nil {
+		return cfg, fmt.Errorf("failed to parse journal config %q: %w", filename, err)
+	}
+	for _, field := range []struct{ read, into *string }{
+		{&read.Currency, &cfg.Currency},
+		{&read.Income, &cfg.Income},
+		{&read.Cash, &cfg.Cash},
+		{&read.Fees, &cfg.Fees},
+		{&read.Gains, &cfg.Gains},
+		{&read.Opening, &cfg.Opening},
+	} {
+		if *field.read != "" {
+			*field.into = *field.read
+		}
+	}
+	cfg.Schedules = read.Schedules
+	return cfg, nil
+}
+
+// schedule returns the commodity and account for a distribution schedule's shares.
+// warned keeps track of which schedules have been warned about already, so it's once each.
+func (cfg journalConfig) schedule(name string, warned map[string]bool) journalSchedule {
+	s := cfg.Schedules[name]
+	if s.Commodity == "" {
+		s.Commodity = defaultJournalCommodity
+		if !warned[name] {
+			warned[name] = true
+			warnf(warnJournalCommodity, "distribution schedule %q has no commodity in --journal-config, so its shares are called %q in the journal", name, s.Commodity)
+		}
+	}
+	if s.Account == "" {
+		s.Account = "Assets:Shareworks:" + journalAccountComponent(name)
+	}
+	return s
+}
+
+// currency is the currency code, or the configured one if the statement didn't say which (as with plain "$").
+func (cfg journalConfig) currency(code string) string {
+	if code == "" {
+		return cfg.Currency
+	}
+	return code
+}
+
+// journalAccountComponent makes a name into something that can be part of an account name:
+// letters and digits only, starting with a capital.  "2021 RSU Plan" becomes "2021RSUPlan".
+func journalAccountComponent(name string) string {
+	var sb strings.Builder
+	for _, r := range name {
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			if sb.Len() == 0 {
+				r = unicode.ToUpper(r)
+			}
+			sb.WriteRune(r)
+		}
+	}
+	if sb.Len() == 0 {
+		return "Unscheduled"
+	}
+	return sb.String()
+}
+
+// grossColumns are where a sale's gross proceeds might be, in order of preference: the line in its "Sale Breakdown" table.
+func (cfg journalConfig) grossColumns() []string {
+	return []string{
+		cfg.breakdownPrefixes.Column("Sale Breakdown", "Gross Proceeds", "Sell"),
+		cfg.breakdownPrefixes.Column("Sale Breakdown", "Gross Proceeds:", "Sell"),
+	}
+}
+
+// netColumns are where a sale's net proceeds might be, in order of preference: the total of its "Sale Breakdown" or "Net Proceeds" table.
+func (cfg journalConfig) netColumns() []string {
+	return []string{
+		cfg.breakdownPrefixes.Total("Sale Breakdown", "Sale Breakdown Total"),
+		cfg.breakdownPrefixes.Total("Net Proceeds", "Net Proceeds Total"),
+	}
+}
+
+// journalEvent is what goes into a journal for a release, a purchase, or a sale.
+type journalEvent struct {
+	kind     string // "release", "purchase", or "sale"; see eventKind.
+	date     time.Time
+	shares   float64
+	price    float64 // per share.
+	currency string  // of the price.
+	// For sales: the gross and net proceeds, in grossCurrency.  Fees are the difference.
+	gross, net    float64
+	grossCurrency string
+}
+
+// readJournalEvent pulls what a journal needs out of an entry (with the normalized columns under their built-in names).
+// If it can't, or the entry isn't one of those, it says why not instead.
+func readJournalEvent(cfg journalConfig, ent map[string]string) (ev journalEvent, whyNot string) {
+	ev.kind = eventKind(ent)
+	dateColumn := map[string]string{
+		"release":  "Release Date:",
+		"purchase": "Purchase Date:",
+		"sale":     "Trade Date:",
+	}[ev.kind]
+	if dateColumn == "" {
+		return ev, "enter it by hand"
+	}
+	var err error
+	ev.date, err = parseDate(ent[dateColumn])
+	if err != nil {
+		ev.date, err = parseDate(ent["Settlement Date:"])
+	}
+	if err != nil {
+		return ev, "it has no date"
+	}
+	ev.shares, err = parseShareCount(ent, ent["stocks report"])
+	if err != nil {
+		return ev, "it has no share count"
+	}
+	ev.price, ev.currency, err = shareworks.ParseEntryMoney(ent, ent["price per unit"])
+	if err != nil {
+		return ev, "it has no price"
+	}
+	ev.currency = cfg.currency(ev.currency)
+	if ev.kind == "sale" {
+		grossFound := false
+		for _, col := range cfg.grossColumns() {
+			if ev.gross, ev.grossCurrency, err = shareworks.ParseEntryMoney(ent, ent[col]); err == nil {
+				grossFound = true
+				break
+			}
+		}
+		if !grossFound {
+			ev.gross, ev.grossCurrency = ev.shares*ev.price, ev.currency
+			warnf(warnJournalProceeds, "sale %q has no gross proceeds (looked in %q), so the journal uses the shares times the price: %s", ent["Event"], strings.Join(cfg.grossColumns(), "\", \""), formatJournalMoney(ev.gross))
+		}
+		ev.grossCurrency = cfg.currency(ev.grossCurrency)
+		netFound := false
+		for _, col := range cfg.netColumns() {
+			if amount, currency, err := shareworks.ParseEntryMoney(ent, ent[col]); err == nil && cfg.currency(currency) == ev.grossCurrency {
+				ev.net, netFound = amount, true
+				break
+			}
+		}
+		if !netFound {
+			ev.net = ev.gross
+			warnf(warnJournalProceeds, "sale %q has no net proceeds in %s (looked in %q), so the journal has it with no fees", ent["Event"], ev.grossCurrency, strings.Join(cfg.netColumns(), "\", \""))
+		}
+	}
+	return ev, ""
+}
+
+// fees is what came out of a sale's proceeds before they were paid out, or zero if it's less than half a cent.
+func (ev journalEvent) fees() float64 {
+	if fees := ev.gross - ev.net; fees >= 0.005 || fees <= -0.005 {
+		return fees
+	}
+	return 0
+}
+
+// formatJournalMoney writes an amount with at least two decimal places, and more only if it has them.
+func formatJournalMoney(f float64) string {
+	s := formatAuditAmount(f)
+	if dot := strings.Index(s, "."); dot >= 0 && len(s)-dot-1 > 2 {
+		return s
+	}
+	return strconv.FormatFloat(f, 'f', 2, 64)
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/warpfork/shareworks-munger/pkg/shareworks"
+)
+
+// The json output is one document per input file:
+//
+//	{
+//	  "metadata": {"source_file": "wow.html", "source_modified": "...", "tool_version": "...", "columns": ["Distribution Schedule", ...]},
+//	  "entries": [{"Distribution Schedule": "...", "Event": "...", ...}, ...]
+//	}
+//
+// The source_modified time is when the input file was last changed, rather than when it was munged,
+// so munging the same file again gives exactly the same json.
+// Each entry only has the fields it actually has values for.
+// All values are strings, exactly as they'd appear in the csv.
+// With --include-raw-html, each entry also gets a "Raw HTML" field, with the tables it was parsed from.
+
+type jsonDocument struct {
+	Metadata jsonMetadata `json:"metadata"`
+	Entries  []jsonEntry  `json:"entries"`
+}
+
+type jsonMetadata struct {
+	SourceFile     string    `json:"source_file"`
+	SourceModified time.Time `json:"source_modified"`
+	ToolVersion    string    `json:"tool_version"`
+	Columns        []string  `json:"columns"`
+}
+
+// jsonEntry marshals as an object with its fields in column order, rather than Go's usual sorted map order,
+// so it reads the same way as the csv.
+type jsonEntry struct {
+	columnOrder []string
+	values      map[string]string
+}
+
+func (e jsonEntry) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	first := true
+	for _, col := range e.columnOrder {
+		value, ok := e.values[col]
+		if !ok {
+			continue
+		}
+		if !first {
+			buf.WriteByte(',')
+		}
+		first = false
+		writeJsonField(&buf, col, value)
+	}
+	if raw, ok := e.values[shareworks.RawHTMLKey]; ok {
+		if !first {
+			buf.WriteByte(',')
+		}
+		writeJsonField(&buf, shareworks.RawHTMLKey, raw)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// writeJsonField writes `"key":"value"`.
+// Unlike json.Marshal, it doesn't turn "<" and ">" into "\u003c" and "\u003e", which would make the raw html unreadable.
+func writeJsonField(buf *bytes.Buffer, key, value string) {
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	enc.Encode(key)             // Encoding a string can't fail.
+	buf.Truncate(buf.Len() - 1) // Encode adds a newline.
+	buf.WriteByte(':')
+	enc.Encode(value)
+	buf.Truncate(buf.Len() - 1)
+}
+
+func emitJson(wr io.Writer, sourceFile string, modified time.Time, columnOrder []string, entries []map[string]string) error {
+	doc := jsonDocument{
+		Metadata: jsonMetadata{
+			SourceFile:     sourceFile,
+			SourceModified: modified,
+			ToolVersion:    toolVersion(),
+			Columns:        columnOrder,
+		},
+		Entries: make([]jsonEntry, len(entries)),
+	}
+	if doc.Metadata.Columns == nil {
+		doc.Metadata.Columns = []string{}
+	}
+	for i, ent := range entries {
+		doc.Entries[i] = jsonEntry{columnOrder, ent}
+	}
+	enc := json.NewEncoder(wr)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "\t")
+	if err := enc.Encode(doc); err != nil {
+		return fmt.Errorf("error while emitting json: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/warpfork/shareworks-munger/pkg/shareworks"
+)
+
+// The ledger output is a plain-text journal for ledger-cli (and hledger), with the same transactions as the beancount output; see journal.go.
+//
+// The difference is in sales.  Ledger doesn't work out what sold shares cost, so the journal says:
+// a sale's shares come out at the price of the release they came from (see lots.go), one posting per release, priced at that cost,
+// and the gains account gets the difference between that and the proceeds, as an amount of its own.
+// (Not "{cost} @ price": hledger ignores the {cost} and balances at the @ price, which would count the gain twice.)
+// The sale price is in a comment on each posting.
+// Releases that --from, --schedule, or --split-by left out of the journal still count for this; they're just not written.
+// Shares from before the statement starts have no release to get a price from, so they come out at the sale price, with a comment saying so.
+
+func emitLedger(wr io.Writer, source string, cfg journalConfig, names nameProfile, history, entries []map[string]string) error {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "; From %s, by shareworks-munger %s.\n\n", source, toolVersion())
+	sorted, written := journalEntries(names, history, entries)
+	// Which releases each sale's shares came from.
+	lotsOf := map[int][]shareMovement{}
+	for _, m := range followShares(sorted) {
+		lotsOf[m.to] = append(lotsOf[m.to], m)
+	}
+	warned := map[string]bool{}
+	for i, ent := range sorted {
+		if !written[i] {
+			continue
+		}
+		title := ent["Event"]
+		ev, whyNot := readJournalEvent(cfg, ent)
+		if whyNot == "" && ev.kind == "purchase" {
+			whyNot = "enter it by hand" // there's no account in the config for the contributions that paid for it.
+		}
+		if whyNot != "" {
+			fmt.Fprintf(&sb, "; Not in the journal, %s: %s (%s)\n\n", whyNot, title, ent["Type"])
+			continue
+		}
+		sched := cfg.schedule(ent["Distribution Schedule"], warned)
+		commodity := ledgerCommodity(sched.Commodity)
+
+		fmt.Fprintf(&sb, "%s * %s\n", ev.date.Format(isoDateLayout), title)
+		fmt.Fprintf(&sb, "    ; source: %s\n", source)
+		posting := func(account, amount string) {
+			if amount == "" {
+				fmt.Fprintf(&sb, "    %s\n", account)
+				return
+			}
+			fmt.Fprintf(&sb, "    %-40s  %s\n", account, amount)
+		}
+		switch ev.kind {
+		case "release":
+			posting(sched.Account, fmt.Sprintf("%s %s @ %s %s", formatShareCount(ev.shares), commodity, formatJournalMoney(ev.price), ev.currency))
+			posting(cfg.Income, fmt.Sprintf("%s %s", formatJournalMoney(-ev.shares*ev.price), ev.currency))
+		case "sale":
+			// Each lot comes out at what it cost.  The gains account gets the difference from the proceeds, worked out here,
+			// since hledger doesn't look at lot costs and can't work it out itself.
+			remaining := ev.shares
+			basis, basisKnown := 0.0, true
+			for _, m := range lotsOf[i] {
+				cost, currency, err := shareworks.ParseEntryMoney(sorted[m.from], sorted[m.from]["price per unit"])
+				if err != nil {
+					continue
+				}
+				posting(sched.Account, fmt.Sprintf("%s %s @ %s %s  ; sold at %s %s", formatShareCount(-m.shares), commodity, formatJournalMoney(cost), cfg.currency(currency), formatJournalMoney(ev.price), ev.currency))
+				remaining -= m.shares
+				basis += m.shares * cost
+				basisKnown = basisKnown && cfg.currency(currency) == ev.grossCurrency
+			}
+			if remaining > shareEpsilon {
+				fmt.Fprintf(&sb, "    ; No release was seen for %s of these shares, so what they cost isn't known; they're at the sale price.\n", formatShareCount(remaining))
+				posting(sched.Account, fmt.Sprintf("%s %s @ %s %s", formatShareCount(-remaining), commodity, formatJournalMoney(ev.price), ev.currency))
+				basis += remaining * ev.price
+				basisKnown = basisKnown && ev.currency == ev.grossCurrency
+			}
+			posting(cfg.Cash, fmt.Sprintf("%s %s", formatJournalMoney(ev.net), ev.grossCurrency))
+			if fees := ev.fees(); fees != 0 {
+				posting(cfg.Fees, fmt.Sprintf("%s %s", formatJournalMoney(fees), ev.grossCurrency))
+			}
+			if basisKnown {
+				posting(cfg.Gains, fmt.Sprintf("%s %s", formatJournalMoney(basis-ev.gross), ev.grossCurrency))
+			} else {
+				// The shares cost something in a different currency from the proceeds; there's no rate to convert with, so leave it to ledger.
+				posting(cfg.Gains, "")
+			}
+		}
+		sb.WriteString("\n")
+	}
+	if _, err := io.WriteString(wr, sb.String()); err != nil {
+		return fmt.Errorf("error while emitting ledger: %w", err)
+	}
+	return nil
+}
+
+// ledgerCommodity quotes a commodity name, unless it's all letters.  Ledger needs quotes around anything with digits or spaces in it.
+func ledgerCommodity(name string) string {
+	for _, r := range name {
+		if !unicode.IsLetter(r) {
+			return strconv.Quote(name)
+		}
+	}
+	return name
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// The logging here is very simple: messages go to stderr, and optionally also to a log file.
+// The log file gets *everything*, including trace messages about every table we looked at,
+// which is way too much noise for a terminal, but is exactly what you want to attach to a bug report.
+
+type logLevel int
+
+const (
+	levelTrace logLevel = iota
+	levelInfo
+	levelWarn
+	levelError
+)
+
+func (l logLevel) String() string {
+	switch l {
+	case levelTrace:
+		return "TRACE"
+	case levelInfo:
+		return "INFO"
+	case levelWarn:
+		return "WARN"
+	case levelError:
+		return "ERROR"
+	default:
+		return "???"
+	}
+}
+
+// logFile, if set, receives every log message at every level.
+var logFile io.Writer
+
+func logf(level logLevel, format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	msg = strings.TrimSuffix(msg, "\n")
+	if logFile != nil {
+		fmt.Fprintf(logFile, "%s %-5s %s\n", time.Now().Format(time.RFC3339), level, msg)
+	}
+	switch level {
+	case levelTrace:
+		// Only goes to the log file.
+	case levelWarn:
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", msg)
+	default:
+		fmt.Fprintf(os.Stderr, "%s\n", msg)
+	}
+}
+
+func tracef(format string, args ...interface{}) { logf(levelTrace, format, args...) }
+func infof(format string, args ...interface{})  { logf(levelInfo, format, args...) }
+func errorf(format string, args ...interface{}) { logf(levelError, format, args...) }
+
+// warnf logs a warning, prefixed with its code -- unless that code was suppressed, in which case it only goes to the log file.
+func warnf(code warningCode, format string, args ...interface{}) {
+	if suppressedWarnings[code] {
+		suppressedWarningCount++
+		logf(levelTrace, "(suppressed) "+string(code)+": "+format, args...)
+		return
+	}
+	logf(levelWarn, string(code)+": "+format, args...)
+}
+
+// cliLogger hands the library's messages to the functions above.
+type cliLogger struct{}
+
+func (cliLogger) Tracef(format string, args ...interface{}) { tracef(format, args...) }
+func (cliLogger) Infof(format string, args ...interface{})  { infof(format, args...) }
+func (cliLogger) Warnf(code string, format string, args ...interface{}) {
+	warnf(warningCode(code), format, args...)
+}
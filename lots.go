@@ -0,0 +1,114 @@
+package main
+
+import (
+	"strconv"
+
+	"github.com/warpfork/shareworks-munger/pkg/shareworks"
+)
+
+// Some outputs need to know which release the shares in each withdrawal came from: the graph draws it, and the ledger journal needs it for cost basis.
+// Shares are followed through each distribution schedule first-in first-out, the same way the balance checks count them.
+// A withdrawal that sells exactly a release's shares the same day it settles is matched to that release first, since that's plainly what happened.
+
+// shareMovement is some shares going from one event (a release or purchase) to another (a withdrawal).
+// The events are indexes into the entries followShares was given.
+type shareMovement struct {
+	from, to int
+	shares   float64
+	sameDay  bool
+}
+
+// chronological returns copies of entries in settlement date order, with the normalized columns under their built-in names again,
+// so the balance helpers can find them, whatever names and sort order the output asked for.
+func chronological(names nameProfile, entries []map[string]string) []map[string]string {
+	sorted := make([]map[string]string, len(entries))
+	for i, ent := range entries {
+		row := make(map[string]string, len(ent))
+		for k, v := range ent {
+			row[names.unrename(k)] = v
+		}
+		sorted[i] = row
+	}
+	shareworks.SortEntries(sorted, dateLayouts, nil)
+	return sorted
+}
+
+// historyKey is where an entry remembers its place in the history of its input (see emitSource),
+// so it can be found there again after it's been filtered, renamed, and transformed.
+// Like the document order, it's never in the column order, so it's never emitted.
+const historyKey = "History Index"
+
+// noteHistory stamps the entries with their place in the history.
+func noteHistory(entries []map[string]string) {
+	for i, ent := range entries {
+		ent[historyKey] = strconv.Itoa(i)
+	}
+}
+
+// journalEntries is chronological, for the journals: all of history, in settlement date order,
+// and which of those events are in entries, and so get written.
+// The rest are only there so that sales can find the releases their shares came from,
+// even if --from, --schedule, or --split-by left those releases out.
+func journalEntries(names nameProfile, history, entries []map[string]string) (sorted []map[string]string, written []bool) {
+	want := map[string]bool{}
+	for _, ent := range entries {
+		want[ent[historyKey]] = true
+	}
+	sorted = chronological(names, history)
+	written = make([]bool, len(sorted))
+	for i, ent := range sorted {
+		written[i] = want[ent[historyKey]]
+	}
+	return sorted, written
+}
+
+// followShares works out where each withdrawal's shares came from.  The entries should be in chronological order.
+// Shares a withdrawal takes that no earlier event brought in (because the statement doesn't go back far enough, say) have no movement.
+func followShares(entries []map[string]string) []shareMovement {
+	// lot is shares from one event that haven't gone anywhere yet.
+	type lot struct {
+		event  int
+		shares float64
+	}
+	var moves []shareMovement
+	balanceKeys := balanceKeysByCurrency(entries)
+	lots := map[string][]lot{} // balance key -> lots, oldest first.
+	for i, ent := range entries {
+		shares, err := parseShareCount(ent, ent["stocks report"])
+		if err != nil {
+			continue
+		}
+		key := balanceKeys[i]
+		switch ent["Type"] {
+		case "Buy":
+			lots[key] = append(lots[key], lot{i, shares})
+		case "Sell":
+			for j := range lots[key] {
+				l := &lots[key][j]
+				if l.shares >= shares-shareEpsilon && isSameDayFullSale(entries[l.event], ent) {
+					l.shares -= shares
+					moves = append(moves, shareMovement{l.event, i, shares, true})
+					shares = 0
+					break
+				}
+			}
+			for j := range lots[key] {
+				if shares <= shareEpsilon {
+					break
+				}
+				l := &lots[key][j]
+				if l.shares <= shareEpsilon {
+					continue
+				}
+				taken := l.shares
+				if shares < taken {
+					taken = shares
+				}
+				l.shares -= taken
+				shares -= taken
+				moves = append(moves, shareMovement{l.event, i, taken, false})
+			}
+		}
+	}
+	return moves
+}
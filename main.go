@@ -1,350 +1,728 @@
 package main
 
 import (
-	"bytes"
+	"compress/gzip"
 	"encoding/csv"
+	"flag"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
-	"sort"
+	"path/filepath"
 	"strings"
 	"time"
 
-	"github.com/PuerkitoBio/goquery"
+	"github.com/warpfork/shareworks-munger/pkg/shareworks"
 )
 
+// options holds everything that can be configured by command line flags.
+type options struct {
+	extraEventsFilename string
+	logFilename         string
+	compress            string
+	format              string
+	excelLocale         string
+	transforms          transformList
+	sameDaySales        string
+	metadataFilename    string
+	warnValueOver       float64
+	warnSharesOver      float64
+	inputFormat         string
+	review              bool
+	dropEventTitle      bool
+	confidenceColumns   bool
+	dateToleranceDays   int
+	dropEmptyColumns    bool
+	totalLabels         []string
+	showNormalization   bool
+	names               nameProfile
+	includeRawHtml      bool
+	output              string
+	keepBackup          bool
+	blankAs             string
+	merge               bool
+	keepDuplicates      bool
+	profile             bool
+	carrySchedule       bool
+	salvage             bool
+	numeric             bool
+	currencyColumns     bool
+	dates               string
+	sort                sortKeys
+	auditColumns        bool
+	auditTolerance      float64
+	dateFilter          dateFilter
+	quarantineDir       string
+	schedules           schedulePatterns
+	numberFormat        shareworks.NumberFormat // "" to work it out from each file.
+	debugJsonFilename   string
+	splitBy             string
+	journalConfigFile   string
+	breakdownPrefixes   shareworks.BreakdownPrefixes
+
+	dialect         csvDialect    // derived from excelLocale and blankAs.
+	journal         journalConfig // read from journalConfigFile.
+	scheduleContext *string       // with carrySchedule, the last distribution schedule heading seen; munge picks up from it, and updates it.
+}
+
 func main() {
-	if len(os.Args) < 2 {
-		fmt.Fprintf(os.Stderr, "Give this program some arguments!  It needs the name of an html file with your data to munge.\n")
+	var opts options
+	flag.StringVar(&opts.extraEventsFilename, "extra-events", "", "a csv file of additional events to merge into the output (for transactions that happened outside of Shareworks).  It should have the same columns this tool emits.")
+	flag.StringVar(&opts.logFilename, "log-file", "", "also write a full log (including a trace of every table looked at) to this file.  Handy for bug reports.")
+	flag.StringVar(&opts.compress, "compress", "", "compress the output.  The only supported value is \"gzip\".")
+	flag.StringVar(&opts.format, "format", "csv", "output format: \"csv\", \"json\", \"timeline-html\" for a page plotting the events over time, \"ics\" for a calendar of vest, trade, and settlement dates, \"beancount\" or \"ledger\" for a journal of releases and sales, \"qif\" for Quicken, or \"dot\" or \"graph-json\" for a graph of which releases the shares in each withdrawal came from.")
+	flag.StringVar(&opts.journalConfigFile, "journal-config", "", "with --format=beancount, ledger, or qif, a json file saying which accounts to use, and what commodity each distribution schedule's shares are.  See the README.")
+	flag.StringVar(&opts.excelLocale, "excel-locale", "", "make a csv that Excel will open correctly by double-clicking, in the given locale (e.g. \"de\" or \"fr\").  Sets the delimiter, decimal separator, and byte order mark to suit.")
+	flag.Var(&opts.transforms, "transform", "adjust a column's values on the way out, as COLUMN=TRANSFORM or COLUMN=TRANSFORM:ARGUMENT.  Transforms are upper, lower, strip-prefix:PREFIX, date:LAYOUT, and negate-sells.  Can be given more than once.")
+	flag.StringVar(&opts.sameDaySales, "same-day-sales", "", "what to do about releases that are sold in full the same day: \"link\" adds a column pointing each to the other; \"collapse\" folds the sale into the release row.  By default, nothing.")
+	flag.StringVar(&opts.metadataFilename, "metadata-file", "", "write a file next to the output recording what it covers: the earliest and latest event dates, the input files, and the version of this tool.")
+	flag.Float64Var(&opts.warnValueOver, "warn-value-over", 0, "warn about any event with a money value bigger than this.  Catches rows where a label and value got mismatched.")
+	flag.Float64Var(&opts.warnSharesOver, "warn-shares-over", 0, "warn about any event with more shares than this.")
+	flag.StringVar(&opts.inputFormat, "input-format", "html", "what the input files are: \"html\" from Shareworks, or \"canonical-csv\" for csv previously emitted by this tool.")
+	flag.BoolVar(&opts.review, "review", false, "list every event that wasn't parsed with high confidence, so you can check them by hand.")
+	flag.BoolVar(&opts.dropEventTitle, "drop-event-title", false, "leave the raw \"Event\" title column out of the output, and just keep the \"Event Date\" and \"Event Description\" columns that are split out of it.")
+	flag.BoolVar(&opts.confidenceColumns, "confidence-columns", false, "include the \"Confidence\" and \"Confidence Note\" columns in the output, saying how sure the parser was about each event.  (--review lists the doubtful ones either way.)")
+	flag.IntVar(&opts.dateToleranceDays, "date-tolerance", 7, "how many days the dates inside an event's table may differ from the date in its title before it's flagged as suspicious.")
+	flag.BoolVar(&opts.dropEmptyColumns, "drop-empty-columns", false, "leave out columns that are empty in every row of the output.")
+	flag.BoolVar(&opts.showNormalization, "show-normalization", false, "print a table of which labels got renamed to which column names (like \"Shares Sold:\" to \"stocks report\").")
+	flag.BoolVar(&opts.includeRawHtml, "include-raw-html", false, "in json output, include the (sanitized) html of the tables each event was parsed from, under \"Raw HTML\".")
+	flag.BoolVar(&opts.merge, "merge", false, "combine all the input files into one output, in chronological order, with a \"Source File\" column saying where each row came from.")
+	flag.BoolVar(&opts.keepDuplicates, "keep-duplicates", false, "with --merge, keep events that are identical to one in an earlier file, instead of dropping them as overlap between statements.")
+	flag.BoolVar(&opts.profile, "profile", false, "at the end, print how long each input file spent in each stage (parse, derive, normalize, emit), and which tables were slowest to parse.")
+	flag.BoolVar(&opts.carrySchedule, "carry-schedule", false, "for a statement that got saved as several files: tables at the start of each file belong to the distribution schedule the previous file ended with.  Give the files in order!")
+	flag.BoolVar(&opts.salvage, "salvage", false, "munge html files that look truncated anyway, instead of stopping.  The last event in such a file gets low confidence, since it may be incomplete.")
+	flag.BoolVar(&opts.numeric, "numeric", false, "write money values as plain numbers (\"$1,234.56 USD\" becomes \"1234.56\"), with the currency in a column of its own next to each, so spreadsheets see numbers instead of text.")
+	flag.BoolVar(&opts.currencyColumns, "currency-columns", false, "add a \"COLUMN Currency\" column after each column of money values, saying which currency each is in (\"C$\" is CAD, \"US$\" is USD, and so on).  --numeric does this too.")
+	flag.StringVar(&opts.dates, "dates", "", "how to write dates: \"iso\" rewrites the statement's \"02-Jan-2006\" dates as \"2006-01-02\".  By default, they're left as the statement had them.")
+	flag.Var(&opts.sort, "sort", "order the output by this column instead of by settlement date, as COLUMN or COLUMN,desc.  Give it more than once to break ties with more columns.  \"none\" keeps the order the events were in in the statement.")
+	flag.BoolVar(&opts.auditColumns, "audit-columns", false, "where a value in the statement (like a sale's gross proceeds) disagrees with what it works out to from the other values, add columns with the computed value and the difference.")
+	flag.Float64Var(&opts.auditTolerance, "audit-tolerance", 0.01, "with --audit-columns, how far apart the statement's value and the computed one can be before it's reported.")
+	flag.Var(&opts.schedules, "schedule", "only output events from distribution schedules matching this name, or glob like \"*RSU*\" (ignoring case).  Can be given more than once.")
+	flag.StringVar(&opts.dateFilter.column, "date-column", "Settlement Date:", "the column --from and --to go by.")
+	from := flag.String("from", "", "only output events on or after this date, like 2023-01-01.")
+	to := flag.String("to", "", "only output events on or before this date, like 2023-12-31.")
+	flag.StringVar(&opts.quarantineDir, "quarantine-dir", "", "copy any input files that fail to parse into this directory, with a report saying why each failed, so they're easy to find after a big run.")
+	flag.StringVar(&opts.debugJsonFilename, "debug-json", "", "write the label/value pairs from every table of every event, as they were in the statement before any normalization, to this file as json.  For writing fixtures and checking the parse itself.")
+	var layouts dateLayoutList
+	flag.Var(&layouts, "date-layout", "a layout to read dates with, written as 2006-01-02 would look (like \"Jan 2, 2006\").  Can be given more than once; they're tried in order.  Replaces the defaults: \""+strings.Join(shareworks.DefaultDateLayouts, "\", \"")+"\".")
+	numberFormat := flag.String("number-format", "auto", "how the input writes its numbers: \"point\" for 1,234.56, \"comma\" for 1.234,56, or \"auto\" to work it out from each file.")
+	flag.StringVar(&opts.blankAs, "blank-as", "", "what to write in csv cells that have no value, e.g. \"N/A\", for importers that treat empty cells as errors.  By default, they're just left empty.")
+	flag.BoolVar(&opts.keepBackup, "backup", false, "when overwriting an output file that has changed, keep the old one with a \".bak\" suffix.")
+	flag.StringVar(&opts.output, "output", "", "write output to this file instead of stdout.  \"{basename}\" in it is replaced by each input file's name (minus the extension), e.g. \"{basename}.csv\".")
+	flag.StringVar(&opts.output, "o", "", "shorthand for --output.")
+	flag.StringVar(&opts.splitBy, "split-by", "", "write several output files instead of one: \"year\" makes one per settlement year, and \"schedule\" one per distribution schedule, with the year or schedule on the end of the output filename (e.g. shareworks-2023.csv).  Needs --output or --output-dir.")
+	outputDir := flag.String("output-dir", "", "write output for each input file into this directory, named after the input file.  Shorthand for --output=DIR/{basename}.csv (or .json, etc).")
+	breakdownPrefixes := flag.String("breakdown-prefixes", "", "prefix columns from breakdown tables with the table's name, e.g. \"Sale Breakdown: Commission\".  \"on\" uses the table names; \"TABLE=PREFIX,...\" picks your own prefixes for some tables.")
+	normalizedNames := flag.String("normalized-names", "", "rename the normalized columns in the output, like \"stocks report=Quantity,price per unit=Price\".")
+	suppressWarnings := flag.String("suppress-warning", "", "comma-separated list of warning codes (like \"W004,W005\") to stop printing, once you've checked they're fine.  They still go to the log file.")
+	totalLabels := flag.String("total-labels", strings.Join(shareworks.DefaultTotalLabels, ","), "comma-separated list of the labels that mark total rows.  Add to this if your statements use something else (e.g. a different language).")
+	flag.Parse()
+	if len(layouts) > 0 {
+		dateLayouts = shareworks.DateLayouts(layouts)
 	}
-	someErrors := false
-	for _, arg := range os.Args[1:] {
-		// Parse the file and munge it.
-		columns, entries, err := munge(arg)
+	for _, label := range strings.Split(*totalLabels, ",") {
+		if label = strings.TrimSpace(label); label != "" {
+			opts.totalLabels = append(opts.totalLabels, label)
+		}
+	}
+
+	if opts.logFilename != "" {
+		f, err := os.Create(opts.logFilename)
 		if err != nil {
-			someErrors = true
-			fmt.Fprintf(os.Stderr, "%q: failed: %s\n", arg, err)
-			continue
+			errorf("%q: failed to open log file: %s", opts.logFilename, err)
+			os.Exit(14)
 		}
-		// Emit csv.
-		emitCsv(os.Stdout, columns, entries)
-		// Done!
-		fmt.Fprintf(os.Stderr, "%q: munged successfully: copy the above to a file (or use shell redirection) to save it.\n", arg)
+		defer f.Close()
+		logFile = f
 	}
-	if someErrors {
+	if *outputDir != "" {
+		if opts.output != "" {
+			errorf("--output and --output-dir can't be used together")
+			os.Exit(14)
+		}
+		opts.output = filepath.Join(*outputDir, "{basename}"+outputExtension(opts))
+	}
+	var err error
+	suppressedWarnings, err = parseSuppressedWarnings(*suppressWarnings)
+	if err != nil {
+		errorf("invalid --suppress-warning: %s", err)
+		os.Exit(14)
+	}
+	opts.names, err = parseNameProfile(*normalizedNames)
+	if err != nil {
+		errorf("invalid --normalized-names: %s", err)
+		os.Exit(14)
+	}
+	opts.breakdownPrefixes, err = shareworks.ParseBreakdownPrefixes(*breakdownPrefixes)
+	if err != nil {
+		errorf("invalid --breakdown-prefixes: %s", err)
+		os.Exit(14)
+	}
+	if *numberFormat != "auto" {
+		var ok bool
+		opts.numberFormat, ok = shareworks.ParseNumberFormat(*numberFormat)
+		if !ok {
+			errorf("unsupported --number-format value %q -- should be \"auto\", \"point\", or \"comma\"", *numberFormat)
+			os.Exit(14)
+		}
+	}
+	if *from != "" {
+		opts.dateFilter.from, err = parseRangeDate(*from)
+		if err != nil {
+			errorf("invalid --from: %s", err)
+			os.Exit(14)
+		}
+	}
+	if *to != "" {
+		opts.dateFilter.to, err = parseRangeDate(*to)
+		if err != nil {
+			errorf("invalid --to: %s", err)
+			os.Exit(14)
+		}
+	}
+	if !opts.dateFilter.from.IsZero() && !opts.dateFilter.to.IsZero() && opts.dateFilter.to.Before(opts.dateFilter.from) {
+		errorf("--to is before --from, so nothing would be left")
 		os.Exit(14)
 	}
+	os.Exit(run(opts))
 }
 
-func munge(filename string) (columns []string, entries []map[string]string, err error) {
-	// Quick sanity check on the file type.
-	if !strings.HasSuffix(filename, ".html") {
-		return nil, nil, fmt.Errorf("not munging file %q; this tool works with html files (a '.html' suffix) only", filename)
+// run does all the work for main, and returns the exit code.
+// (It's separate just so that the deferred stuff in main gets to happen before exiting.)
+func run(opts options) (exitCode int) {
+	if flag.NArg() < 1 {
+		errorf("Give this program some arguments!  It needs the name of an html file with your data to munge.")
 	}
 
-	// Pop 'er open.
-	bs, err := ioutil.ReadFile(filename)
-	if err != nil {
-		return nil, nil, fmt.Errorf("failed to open html file %q: %w", filename, err)
+	// Stdin can only be read once.
+	stdinCount := 0
+	for _, arg := range flag.Args() {
+		if arg == stdinFilename {
+			stdinCount++
+		}
 	}
-	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(bs))
-	if err != nil {
-		return nil, nil, fmt.Errorf("failed to open html file %q: %w", filename, err)
+	if stdinCount > 1 {
+		errorf("\"-\" (standard input) can only be given once")
+		return 14
+	}
+
+	// With several input files, they each need their own output file.
+	if opts.output != "" && flag.NArg() > 1 && !opts.merge && !strings.Contains(opts.output, "{basename}") {
+		errorf("with more than one input file, --output needs \"{basename}\" in it, so each one gets its own output file")
+		return 14
+	}
+
+	if err := checkOutputCompression(opts.output, opts.compress); err != nil {
+		errorf("%s", err)
+		return 14
 	}
 
-	// Check for the most likely data collection error and warn about it specifically.
-	if doc.Find("iframe#transaction-statement-iframe").Length() > 0 {
-		return nil, nil, fmt.Errorf("wrong html -- it looks like you got the enclosing document.  Check the README again -- did you do extraction correctly?  You have to get the content from inside the iframe element.  (Sorry this is complicated.  I didn't write the website.)")
-	}
-
-	// All the relevant data is in tables with this class.
-	//  A lot of irrelevant data is too, but we'll sort that out later.
-	tablesSelection := doc.Find("table.sw-datatable")
-	if tablesSelection.Length() < 1 {
-		return nil, nil, fmt.Errorf("found no shareworks data tables -- are you sure this is the right html?")
-	}
-
-	// Pluck out tables that have a header row that contains the text "Release".
-	//  The "Release" tables are the only ones that are useful.
-	//  (Other tables contain summaries, but the summaries are... basically useless, and exclude all of the facts that are actually relevant.  Amazing.)
-	tablesSelection = tablesSelection.FilterFunction(func(i int, sel *goquery.Selection) bool {
-		headerText := sel.Find("th.newReportTitleStyle").First().Text()
-		return strings.Contains(headerText, "Release")
-	})
-	if tablesSelection.Length() < 1 {
-		return nil, nil, fmt.Errorf("none of the shareworks data tables had titles containing the word 'Release' -- are you sure this is the right html?  We expected the events to all have 'Release' in the title somewhere.")
-	}
-
-	// BUT WAIT!  THERE'S MORE!
-	// Look for h2 tags.  These contain the info about which kind of good we're handling.
-	//  This is super important if you have more than one kind of stock or token being reported.
-	//  Note that this information is NOT the actual stock or good itself -- it's the distribution schedule name.
-	//   You'll have to demux that information back onto the actual stock or good manually with information in your hands as a human -- the document **literally** does not contain this information, as far as I can tell.
-	// We have to do this in *the same query* as getting the tables, so that they're interleaved in the correct order in our selection here --
-	//  the h2 tags aren't parents of the data they describe, they're just *before* the data they describe.  Additional "whee" for parsing :))))
-	//   Can you imagine how great it would be if these tables actually say which unit they're denominated in?  But they don't :D :D :D :D
-	//  So, that tablesSelection var earlier is demoted to just being another sanitychecker, and we'll loop over this below, looking for both tables and h2 tags.
-	//   And we'll be re-doing the filter for tables-that-are-actually-relevant below, too.  Agghsdfhwefhsdfh.
-	tablesAndHeadersSelection := doc.Find("h2, table.sw-datatable")
-
-	// Okay, it's almost time to start accumulating data.
-	// I'm gonna kinda try to normalize this to columnar as we go;
-	//  and I'm not hard-coding any column headings,
-	//   so, first encounter with a data entry in the whole document determins the order in which it will appear as a column.
-	// See the definition of `columns` and `entries` at the top, in the function's returns.
-
-	// We also need one slot of memory to remember the text of the last h2 tag we saw,
-	//  because that's the distribution schedule name, and will apply to several rows, which we're about to loop over.
-	var distributionScheduleName string
-
-	// Go over the whole melange.
-	// The headers become one column; the tables that are relevant each become one row in our sanitized data.
-	// Yeah, one table becomes one row.  Yeah.  Yeahhhhh.
-	// This is why your accountant didn't want to work with this format.  Because it's insane.  This is not how data should be formatted.
-	// Anyway, let's go:
-	tablesAndHeadersSelection.Each(func(i int, sel *goquery.Selection) {
-		// First: see if this is:
-		//  - a heading (e.g. might indicate which distribution schedule the following tables are for),
-		//  - or if it's a table that we care about (e.g. it describes a distribution event),
-		//  - or if it's one of the other tables that's useless (see earlier comments).
-		// If it's a heading, we'll handle that in this logic block;
-		// if it's a useless table, we'll skip out;
-		// if it's a relevant table, the majority of the logic will continue below.
-		switch {
-		case sel.Is("h2"):
-			distributionScheduleName = strings.TrimPrefix(strings.TrimSpace(sel.Text()), "Summary of ")
-			return
-		case sel.Is("table.sw-datatable"):
-			headerText := sel.Find("th.newReportTitleStyle").First().Text()
-			isRelease := strings.Contains(headerText, "Release")
-			isWithdrawal := strings.Contains(headerText, "Withdrawal on")
-			if !isRelease && !isWithdrawal {
-				return
+	// Figure out where output to stdout goes.
+	var out io.Writer = os.Stdout
+	switch opts.compress {
+	case "":
+		// Nothing to do.
+	case "gzip":
+		if opts.output != "" {
+			break // Output files get compressed one by one, and nothing goes to stdout, not even an empty gzip stream.
+		}
+		gz := gzip.NewWriter(os.Stdout)
+		// Closing is what writes the end of the stream, so if that fails, the output is no good either.
+		defer func() {
+			if err := gz.Close(); err != nil {
+				errorf("failed to finish the gzip output: %s", err)
+				exitCode = 14
 			}
-			// if it contains either word, it's relevant: continue...
-		default:
-			panic("unreachable, earlier filter should not have matched this")
+		}()
+		out = gz
+	default:
+		errorf("unsupported --compress value %q -- only \"gzip\" is supported", opts.compress)
+		return 14
+	}
+
+	switch opts.format {
+	case "csv", "json", "timeline-html", "ics", "beancount", "ledger", "qif", "dot", "graph-json":
+		// Good.
+	default:
+		errorf("unsupported --format value %q -- should be \"csv\", \"json\", \"timeline-html\", \"ics\", \"beancount\", \"ledger\", \"qif\", \"dot\", or \"graph-json\"", opts.format)
+		return 14
+	}
+
+	isJournal := opts.format == "beancount" || opts.format == "ledger" || opts.format == "qif"
+	if opts.journalConfigFile != "" && !isJournal {
+		errorf("--journal-config only works with --format=beancount, --format=ledger, or --format=qif")
+		return 14
+	}
+	if isJournal {
+		var err error
+		opts.journal, err = readJournalConfig(opts.journalConfigFile)
+		if err != nil {
+			errorf("%s", err)
+			return 14
 		}
+		opts.journal.breakdownPrefixes = opts.breakdownPrefixes
+	}
 
-		// Make some temporary memory to put this row's data in as we find it.
-		row := map[string]string{}
-		entries = append(entries, row)
+	if opts.includeRawHtml && opts.format != "json" {
+		errorf("--include-raw-html only works with --format=json")
+		return 14
+	}
 
-		// Append the distributionScheduleName as a column.
-		accumulate(&columns, row, "Distribution Schedule", distributionScheduleName)
+	switch opts.dates {
+	case "", "iso":
+		// Good.
+	default:
+		errorf("unsupported --dates value %q -- the only supported value is \"iso\"", opts.dates)
+		return 14
+	}
+	if opts.dates != "" && opts.format != "csv" && opts.format != "json" {
+		errorf("--dates only works with --format=csv or --format=json")
+		return 14
+	}
 
-		// Pick a title for the event.
-		//  We'll use that same table header that we happened to already look at above to filter the tables in the first place.
-		headerText := strings.TrimSpace(sel.Find("th.newReportTitleStyle").First().Text())
-		accumulate(&columns, row, "Event", headerText)
+	switch opts.inputFormat {
+	case "html", "canonical-csv":
+		// Good.
+	default:
+		errorf("unsupported --input-format value %q -- should be \"html\" or \"canonical-csv\"", opts.inputFormat)
+		return 14
+	}
+	if opts.debugJsonFilename != "" && opts.inputFormat != "html" {
+		errorf("--debug-json only works with --input-format=html; there are no statement tables in a csv")
+		return 14
+	}
 
-		// Add the Type column
-		if strings.Contains(headerText, "Release") {
-			accumulate(&columns, row, "Type", "Buy")
-		} else if strings.Contains(headerText, "Withdrawal on") {
-			accumulate(&columns, row, "Type", "Sell")
-		}
+	switch opts.sameDaySales {
+	case "", "link", "collapse":
+		// Good.
+	default:
+		errorf("unsupported --same-day-sales value %q -- should be \"link\" or \"collapse\"", opts.sameDaySales)
+		return 14
+	}
+	if opts.sameDaySales == "collapse" && isJournal {
+		// The journals need the sales as transactions of their own, and collapsing takes them away.
+		errorf("--same-day-sales=collapse doesn't work with --format=%s: the sales would be left out of the journal.  Use \"link\", or nothing", opts.format)
+		return 14
+	}
 
-		// Some brain genius made a four-column layout: two columns of two paired columns.  KVKV.
-		// So we get to suss that back out.  Neato.
-		// They tend to read top-bottom and then top-bottom again, and I'm actually going to bother to parse that ordering.
-		var col1, col2, col3, col4 []string
-		sel.Find("tr").Each(func(i int, sel *goquery.Selection) {
-			sel.Find("td.staticViewTableColumn1").Each(func(i int, sel *goquery.Selection) {
-				if i%2 == 0 {
-					col1 = append(col1, strings.TrimSpace(sel.Text()))
-				} else {
-					col3 = append(col3, strings.TrimSpace(sel.Text()))
-				}
-			})
-			sel.Find("td.staticViewTableColumn2").Each(func(i int, sel *goquery.Selection) {
-				if i%2 == 0 {
-					col2 = append(col2, strings.TrimSpace(sel.Text()))
-				} else {
-					col4 = append(col4, strings.TrimSpace(sel.Text()))
-				}
-			})
-		})
-		for i := range col1 {
-			accumulate(&columns, row, col1[i], col2[i])
+	switch opts.splitBy {
+	case "", "year", "schedule":
+		// Good.
+	default:
+		errorf("unsupported --split-by value %q -- should be \"year\" or \"schedule\"", opts.splitBy)
+		return 14
+	}
+
+	opts.dialect = defaultCsvDialect
+	if opts.excelLocale != "" {
+		var err error
+		opts.dialect, err = excelLocaleDialect(opts.excelLocale)
+		if err != nil {
+			errorf("%s", err)
+			return 14
 		}
-		for i := range col3 {
-			accumulate(&columns, row, col3[i], col4[i])
+	}
+	opts.dialect.blank = opts.blankAs
+
+	// If there's a file of manually-entered events, load that up front.
+	//  It's the same for every input file, so there's no sense in re-reading it each time.
+	var extraColumns []string
+	var extraEntries []map[string]string
+	if opts.extraEventsFilename != "" {
+		var err error
+		extraColumns, extraEntries, err = readCanonicalCsv(opts.extraEventsFilename, opts.names, opts.numberFormat)
+		if err != nil {
+			errorf("%q: failed: %s", opts.extraEventsFilename, err)
+			return 14
 		}
+	}
 
-		// Process additional tables that follow the main table
-		if strings.Contains(headerText, "Release") {
-			// For releases, find and process the "Value of Shares Sold" table that follows
-			nextTable := sel.Next()
-			if nextTable.Length() > 0 && nextTable.Is("table.sw-datatable") {
-				// Check if it's a "Value of Shares Sold" table
-				headerText := nextTable.Find("th.newReportHeadingStyle").First().Text()
-				if strings.TrimSpace(headerText) == "Value of Shares Sold" {
-					processValueTable(nextTable, &columns, row)
-
-					// Get the total value from the next table
-					totalTable := nextTable.Next()
-					if totalTable.Length() > 0 && totalTable.Is("table.sw-datatable") {
-						totalText := totalTable.Find("td.defaultTableModelTextBold").First().Text()
-						if strings.HasPrefix(totalText, "Total Value:") {
-							accumulate(&columns, row, "Total Value", strings.TrimSpace(strings.TrimPrefix(totalText, "Total Value:")))
-						}
-					}
+	// If someone dropped a file onto us in Windows Explorer, there's no console that'll stick around long enough to read.
+	//  So in that case, write the csv next to each input file instead, and pop up a dialog at the end saying what happened.
+	doubleClicked := launchedWithoutConsole()
+	var summary []string
+	if opts.splitBy != "" && opts.output == "" && !doubleClicked {
+		errorf("--split-by writes several files, so it needs --output or --output-dir to say where")
+		return 14
+	}
+	if doubleClicked && flag.NArg() < 1 {
+		notify("shareworks-munger", "Drag an html file with your Shareworks data onto this program to munge it.")
+	}
+
+	var record runRecord
+	usedOutputNames := outputNames{}
+	quarantined := quarantine{dir: opts.quarantineDir}
+	someErrors := false
+	// Parse the files and munge them.
+	var batches []inputBatch
+	if opts.carrySchedule {
+		opts.scheduleContext = new(string)
+	}
+	for _, arg := range flag.Args() {
+		start := time.Now()
+		columns, entries, err := load(arg, opts)
+		noteTiming(arg, stageParse, start)
+		if err != nil {
+			someErrors = true
+			errorf("%q: failed: %s", arg, err)
+			summary = append(summary, fmt.Sprintf("%s: failed: %s", filepath.Base(arg), err))
+			if opts.quarantineDir != "" {
+				if err := quarantined.add(arg, err); err != nil {
+					errorf("%s", err)
 				}
 			}
-		} else if strings.Contains(headerText, "Withdrawal on") {
-			// For withdrawals, process all the following tables until we hit a non-relevant one
-			currentTable := sel.Next()
-			for currentTable.Length() > 0 {
-				if !currentTable.Is("table.sw-datatable") {
-					break
-				}
-
-				headerText := currentTable.Find("th.newReportHeadingStyle, th.newReportTitleStyle").First().Text()
-				if headerText == "" {
-					currentTable = currentTable.Next()
+			continue
+		}
+		batches = append(batches, inputBatch{source: arg, name: arg, modified: inputModTime(arg), columns: columns, entries: entries})
+	}
+	// If we're merging, everything that loaded becomes one big batch.
+	//  (The per-file record is taken now, since afterwards there's no telling the files apart.
+	//  It's of what --from, --to, and --schedule will leave of each file, the same as without merging.)
+	if opts.merge && len(batches) > 0 {
+		var sources []string
+		var modified time.Time
+		for _, b := range batches {
+			record.add(b.source, b.modified, opts.filterEntries(b.entries))
+			sources = append(sources, b.source)
+			if b.modified.After(modified) {
+				modified = b.modified
+			}
+		}
+		columns, entries := combineEntries(batches, opts.keepDuplicates)
+		batches = []inputBatch{{
+			source:   strings.Join(sources, ", "),
+			name:     filepath.Join(filepath.Dir(sources[0]), "merged"),
+			merged:   true,
+			modified: modified,
+			columns:  columns,
+			entries:  entries,
+		}}
+	}
+	for _, batch := range batches {
+		arg, columns, entries := batch.source, batch.columns, batch.entries
+		start := time.Now()
+		// Fold in any manually-entered events.
+		if extraEntries != nil {
+			columns, entries = mergeEntries(columns, entries, extraColumns, extraEntries)
+		}
+		// Sanity check the share counts, and warn if anything looks off.
+		reconcileBalances(entries)
+		checkEventDates(&columns, entries, opts.dateToleranceDays)
+		checkThresholds(entries, columns, opts.warnValueOver, opts.warnSharesOver)
+		if opts.auditColumns {
+			auditValues(&columns, entries, opts.breakdownPrefixes, opts.auditTolerance)
+		}
+		if opts.review {
+			reviewConfidence(arg, entries)
+		}
+		// Link up releases that were sold the same day, if asked.
+		if opts.sameDaySales != "" {
+			columns, entries = linkSameDaySales(columns, entries, opts.sameDaySales)
+		}
+		// Remember everything, before some of it gets filtered out: the journals need the earlier releases to know what sold shares cost.
+		noteHistory(entries)
+		src := emitSource{name: arg, modified: batch.modified, history: entries}
+		entries = opts.filterEntries(entries)
+		if !batch.merged {
+			record.add(arg, batch.modified, entries)
+		}
+		noteTiming(arg, stageDerive, start)
+		// Break it up into several outputs, if asked.  Otherwise this is just the one.
+		parts := splitEntries(opts.splitBy, entries)
+		if len(parts) == 0 {
+			infof("%q: no events left to write, so no output files for it.", arg)
+		}
+		for _, part := range parts {
+			columns, entries := columns, part.entries
+			start = time.Now()
+			// Rename columns, and apply any output transforms.
+			//  (Renaming first, so the transforms can use the names you'll see in the output.)
+			columns, entries = opts.names.apply(columns, entries)
+			if len(opts.sort) > 0 {
+				entries = opts.sort.apply(entries)
+			}
+			entries = opts.transforms.apply(entries)
+			if opts.dates == "iso" {
+				entries = isoDates(columns, entries, opts.transforms.dateColumns())
+			}
+			if opts.numeric || opts.currencyColumns {
+				columns, entries = currencyColumns(columns, entries, opts.numeric)
+			}
+			if opts.dropEventTitle {
+				columns = withoutColumn(columns, "Event")
+			}
+			if !opts.confidenceColumns {
+				columns = withoutColumn(withoutColumn(columns, "Confidence"), "Confidence Note")
+			}
+			if opts.dropEmptyColumns {
+				columns = nonEmptyColumns(columns, entries)
+			}
+			noteTiming(arg, stageNormalize, start)
+			start = time.Now()
+			// Emit!
+			//  Either to a file (if we were told one, or if there's no console to print on), or to stdout.
+			outFilename := ""
+			switch {
+			case opts.output != "":
+				outFilename = outputFilename(opts.output, batch.name)
+			case doubleClicked:
+				outFilename = strings.TrimSuffix(batch.name, filepath.Ext(batch.name)) + outputExtension(opts)
+			}
+			if outFilename != "" {
+				outFilename = usedOutputNames.claim(splitFilename(outFilename, part.suffix))
+				unchanged, err := writeOutputFile(outFilename, opts, src, columns, entries)
+				noteTiming(arg, stageEmit, start)
+				if err != nil {
+					someErrors = true
+					errorf("%q: failed: %s", arg, err)
+					summary = append(summary, fmt.Sprintf("%s: failed: %s", filepath.Base(arg), err))
 					continue
 				}
-				headerText = strings.TrimSpace(headerText)
-
-				// Process tables based on their headers
-				switch headerText {
-				case "Sale Breakdown", "Electronic Share Transfer", "Mail cash to broker", "Net Proceeds":
-					processValueTable(currentTable, &columns, row)
-
-					// Check for total value table
-					totalTable := currentTable.Next()
-					if totalTable.Length() > 0 && totalTable.Is("table.sw-datatable") {
-						totalText := totalTable.Find("td.defaultTableModelTextBold").First().Text()
-						if strings.HasPrefix(totalText, "Total Value:") {
-							accumulate(&columns, row, headerText+" Total", strings.TrimSpace(strings.TrimPrefix(totalText, "Total Value:")))
-							currentTable = totalTable.Next()
-							continue
-						}
-					}
+				if unchanged {
+					infof("%q: munged successfully: %q is unchanged.", arg, outFilename)
+					summary = append(summary, fmt.Sprintf("%s: munged successfully: %s is unchanged", filepath.Base(arg), outFilename))
+					continue
 				}
-				currentTable = currentTable.Next()
+				infof("%q: munged successfully: saved to %q.", arg, outFilename)
+				summary = append(summary, fmt.Sprintf("%s: munged successfully: saved to %s", filepath.Base(arg), outFilename))
+				continue
 			}
+			err := emit(out, opts, src, columns, entries)
+			noteTiming(arg, stageEmit, start)
+			if err != nil {
+				someErrors = true
+				errorf("%q: failed: %s", arg, err)
+				continue
+			}
+			// Done!
+			infof("%q: munged successfully: copy the above to a file (or use --output) to save it.", arg)
 		}
-	})
-
-	// Sort entries by Settlement Date
-	sort.Slice(entries, func(i, j int) bool {
-		date1, ok1 := entries[i]["Settlement Date:"]
-		date2, ok2 := entries[j]["Settlement Date:"]
-
-		// If either entry doesn't have a Settlement Date, keep original order
-		if !ok1 || !ok2 {
-			return false
-		}
-
-		// Parse dates in the format "02-Jan-2006"
-		t1, err1 := time.Parse("02-Jan-2006", date1)
-		if err1 != nil {
-			fmt.Fprintf(os.Stderr, "Warning: Could not parse date %q: %v\n", date1, err1)
-			return false
+	}
+	if opts.showNormalization {
+		printNormalizationReport(os.Stderr)
+	}
+	if opts.profile {
+		printProfileReport(os.Stderr)
+	}
+	if opts.metadataFilename != "" {
+		if err := record.write(opts.metadataFilename); err != nil {
+			someErrors = true
+			errorf("%s", err)
 		}
-		t2, err2 := time.Parse("02-Jan-2006", date2)
-		if err2 != nil {
-			fmt.Fprintf(os.Stderr, "Warning: Could not parse date %q: %v\n", date2, err2)
-			return false
+	}
+	if opts.debugJsonFilename != "" {
+		if err := writeDebugJson(opts.debugJsonFilename); err != nil {
+			someErrors = true
+			errorf("%s", err)
 		}
-		return t1.Before(t2)
-	})
+	}
+	if err := quarantined.writeReport(); err != nil {
+		errorf("%s", err)
+	}
+	if suppressedWarningCount > 0 {
+		infof("(%d suppressed warnings; they're in the log file, if you're using one.)", suppressedWarningCount)
+	}
+	if doubleClicked && len(summary) > 0 {
+		notify("shareworks-munger", strings.Join(summary, "\n"))
+	}
+	if someErrors {
+		return 14
+	}
+	return 0
+}
 
+// load reads an input file of whichever format, and munges it if necessary.
+func load(filename string, opts options) (columns []string, entries []map[string]string, err error) {
+	switch opts.inputFormat {
+	case "html":
+		columns, entries, err = munge(filename, opts)
+	case "canonical-csv":
+		// Nothing to munge; it's already been munged.
+		columns, entries, err = readCanonicalCsv(filename, opts.names, opts.numberFormat)
+	default:
+		panic("unreachable, input format was checked earlier")
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	// Remember what order they came in, in case of --sort=none.  Then put them in order, which is what everything else expects.
+	noteDocumentOrder(entries)
+	shareworks.SortEntries(entries, dateLayouts, cliLogger{})
 	return columns, entries, nil
 }
 
-// Helper function to process value tables (used for both Release and Withdrawal tables)
-func processValueTable(table *goquery.Selection, columns *[]string, row map[string]string) {
-	table.Find("tr").Each(func(i int, tr *goquery.Selection) {
-		// Skip the header row
-		if i == 0 {
-			return
-		}
+// stdinFilename is the filename that means "read standard input", for piping html straight in.
+const stdinFilename = "-"
 
-		// Get the key and value from the cells
-		var key, value string
-		tr.Find("td.newReportCellStyle").Each(func(j int, td *goquery.Selection) {
-			text := strings.TrimSpace(td.Text())
-			if j == 0 {
-				key = text
-			} else if j == 1 {
-				value = text
-			}
-		})
-		if key != "" && value != "" {
-			accumulate(columns, row, key, value)
+// openInput opens an input file, or stdin if the filename is "-".
+func openInput(filename string) (io.ReadCloser, error) {
+	if filename == stdinFilename {
+		return ioutil.NopCloser(os.Stdin), nil
+	}
+	return os.Open(filename)
+}
+
+// inputModTime is when an input file was last modified, which the json and ics outputs use as their timestamp.
+// (Rather than the time of the run, so that munging the same file again makes the same output, and --output can leave it alone.)
+// Stdin, or a file that can't be looked at, has no modification time, so it gets the time of the run after all.
+func inputModTime(filename string) time.Time {
+	if filename != stdinFilename {
+		if fi, err := os.Stat(filename); err == nil {
+			return fi.ModTime().UTC().Truncate(time.Second)
 		}
-	})
+	}
+	return time.Now().UTC().Truncate(time.Second)
 }
 
-func normalizeColumnName(originalName, eventType string) string {
-	switch {
-	case eventType == "Buy" && originalName == "Number of Restricted Awards Disbursed:":
-		return "stocks report"
-	case eventType == "Sell" && originalName == "Shares Sold:":
-		return "stocks report"
-	case eventType == "Buy" && originalName == "Release Price:":
-		return "price per unit"
-	case eventType == "Sell" && originalName == "Market Price Per Unit:":
-		return "price per unit"
-	default:
-		return originalName
+// munge reads one html statement, and hands it to the parser in pkg/shareworks.
+func munge(filename string, opts options) (columns []string, entries []map[string]string, err error) {
+	// Quick sanity check on the file type.  (Stdin doesn't have a suffix; we'll just have to trust it.)
+	if filename != stdinFilename && !strings.HasSuffix(filename, ".html") && !shareworks.IsMhtmlFilename(filename) {
+		return nil, nil, fmt.Errorf("not munging file %q; this tool works with html files (a '.html' suffix) and single-file page saves (a '.mht' or '.mhtml' suffix) only", filename)
+	}
+
+	// Pop 'er open.
+	f, err := openInput(filename)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open html file %q: %w", filename, err)
+	}
+	defer f.Close()
+
+	parseOpts := []shareworks.Option{
+		shareworks.WithFilename(filename),
+		shareworks.WithSidecarFiles(),
+		shareworks.WithLogger(cliLogger{}),
+		shareworks.WithDocumentOrder(),
+		shareworks.WithTotalLabels(opts.totalLabels),
+		shareworks.WithBreakdownPrefixes(opts.breakdownPrefixes),
+		shareworks.WithNormalizationHook(noteNormalization),
+		shareworks.WithTableTimingHook(func(title string, d time.Duration) { noteTableTiming(filename, title, d) }),
+	}
+	if opts.includeRawHtml {
+		parseOpts = append(parseOpts, shareworks.WithRawHTML())
+	}
+	if opts.salvage {
+		parseOpts = append(parseOpts, shareworks.WithSalvage())
+	}
+	if opts.numberFormat != "" {
+		parseOpts = append(parseOpts, shareworks.WithNumberFormat(opts.numberFormat))
+	}
+	if opts.scheduleContext != nil {
+		parseOpts = append(parseOpts, shareworks.WithScheduleContext(opts.scheduleContext))
+	}
+	if opts.debugJsonFilename != "" {
+		parseOpts = append(parseOpts, shareworks.WithDebug())
+	}
+	stmt, err := shareworks.Parse(f, parseOpts...)
+	if err != nil {
+		return nil, nil, err
 	}
+	if opts.debugJsonFilename != "" {
+		noteDebugEvents(filename, stmt.Debug)
+	}
+	return stmt.Columns, stmt.Entries, nil
+}
+
+// filterEntries returns just the entries that --from, --to, and --schedule keep.
+func (opts options) filterEntries(entries []map[string]string) []map[string]string {
+	if opts.dateFilter.active() {
+		entries = opts.dateFilter.apply(entries)
+	}
+	if len(opts.schedules) > 0 {
+		entries = opts.schedules.apply(entries)
+	}
+	return entries
 }
 
-func accumulate(columnOrder *[]string, row map[string]string, key string, value string) {
-	// Get the event type from the row
-	eventType := row["Type"]
-
-	// Normalize the column name
-	normalizedKey := normalizeColumnName(key, eventType)
-
-	// If the key was normalized, we need to handle both the normalized and original names
-	if normalizedKey != key {
-		row[normalizedKey] = value
-		// Check if we need to add the normalized column name
-		found := false
-		for _, col := range *columnOrder {
-			if col == normalizedKey {
-				found = true
+// withoutColumn returns a copy of the column order, minus one column.
+// (The entries can keep their values for it; only columns in the column order get emitted.)
+func withoutColumn(columnOrder []string, drop string) []string {
+	var result []string
+	for _, col := range columnOrder {
+		if col != drop {
+			result = append(result, col)
+		}
+	}
+	return result
+}
+
+// nonEmptyColumns returns the column order, minus any columns that don't have a value in any of the entries.
+func nonEmptyColumns(columnOrder []string, entries []map[string]string) []string {
+	var result []string
+	for _, col := range columnOrder {
+		for _, ent := range entries {
+			if ent[col] != "" {
+				result = append(result, col)
 				break
 			}
 		}
-		if !found {
-			*columnOrder = append(*columnOrder, normalizedKey)
-		}
-		return
 	}
+	return result
+}
 
-	// Original accumulate logic for non-normalized keys
-	row[key] = value
-	for _, col := range *columnOrder {
-		if col == key {
-			return
-		}
+// emitSource is what emit is told about where the entries came from.
+type emitSource struct {
+	name     string              // the input filename (or a list of them, if merged), which some formats record.
+	modified time.Time           // when the input was last changed; see inputModTime.
+	history  []map[string]string // every entry from the input, before --from, --to, --schedule, and --split-by; see noteHistory.
+}
+
+// emit writes the entries in whatever format was asked for.
+func emit(wr io.Writer, opts options, src emitSource, columnOrder []string, entries []map[string]string) error {
+	switch opts.format {
+	case "csv":
+		return emitCsv(wr, opts.dialect, columnOrder, entries)
+	case "json":
+		return emitJson(wr, src.name, src.modified, columnOrder, entries)
+	case "timeline-html":
+		return emitTimelineHtml(wr, columnOrder, entries)
+	case "ics":
+		return emitIcs(wr, src.name, src.modified, entries)
+	case "beancount":
+		return emitBeancount(wr, src.name, opts.journal, opts.names, src.history, entries)
+	case "ledger":
+		return emitLedger(wr, src.name, opts.journal, opts.names, src.history, entries)
+	case "qif":
+		return emitQif(wr, opts.journal, opts.names, entries)
+	case "dot":
+		return emitDot(wr, src.name, opts.names, entries)
+	case "graph-json":
+		return emitGraphJson(wr, opts.names, entries)
+	default:
+		panic("unreachable, format was checked earlier")
 	}
-	*columnOrder = append(*columnOrder, key)
 }
 
-func emitCsv(wr io.Writer, columnOrder []string, entries []map[string]string) error {
+func emitCsv(wr io.Writer, dialect csvDialect, columnOrder []string, entries []map[string]string) error {
+	if dialect.bom {
+		if _, err := io.WriteString(wr, "\uFEFF"); err != nil {
+			return fmt.Errorf("error while emitting csv: %w", err)
+		}
+	}
 	c := csv.NewWriter(wr)
 	c.UseCRLF = true
+	c.Comma = dialect.delimiter
 	// Write the first row, which is column headers.
 	if err := c.Write(columnOrder); err != nil {
-		fmt.Errorf("error while emitting csv: %w", err)
+		return fmt.Errorf("error while emitting csv: %w", err)
 	}
 	// Write the rest.
 	row := make([]string, len(columnOrder))
 	for _, ent := range entries {
 		row = row[0:0]
 		for _, col := range columnOrder {
-			row = append(row, ent[col])
+			value := ent[col]
+			// (Values from a statement that already writes decimal commas are fine as they are.)
+			if dialect.decimalComma && shareworks.NumberFormatOf(ent) == shareworks.DecimalPoint {
+				value = toDecimalComma(value)
+			}
+			if value == "" {
+				value = dialect.blank
+			}
+			row = append(row, value)
 		}
 		if err := c.Write(row); err != nil {
-			fmt.Errorf("error while emitting csv: %w", err)
+			return fmt.Errorf("error while emitting csv: %w", err)
 		}
 	}
 	c.Flush()
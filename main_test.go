@@ -0,0 +1,491 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// These tests run the built binary the way a person would: against the statements in testdata/statements, with some flags.
+// Everything it writes (stdout, and any files) is compared with what's in testdata/cli/CASE, and the exit code and stderr are checked too.
+//
+// After a change that's meant to change the output, run `go test -run TestCLI -update` to rewrite the expected files, and read the diff.
+
+var update = flag.Bool("update", false, "rewrite the expected output in testdata/cli with what the binary writes now.")
+
+// binary is the shareworks-munger built for the tests, by TestMain.
+var binary string
+
+// fixtureModTime is what the copies of the fixtures get as their modification time, since the json and ics outputs record it.
+var fixtureModTime = time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+func TestMain(m *testing.M) {
+	flag.Parse()
+	dir, err := ioutil.TempDir("", "shareworks-munger-test")
+	if err != nil {
+		panic(err)
+	}
+	binary = filepath.Join(dir, "shareworks-munger")
+	if runtime.GOOS == "windows" {
+		binary += ".exe"
+	}
+	build := exec.Command("go", "build", "-ldflags", "-X main.version=test", "-o", binary, ".")
+	build.Stdout, build.Stderr = os.Stderr, os.Stderr
+	if err := build.Run(); err != nil {
+		os.RemoveAll(dir)
+		panic(err)
+	}
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+type cliCase struct {
+	name   string
+	args   []string
+	exit   int
+	stderr []string // things stderr should say (each somewhere, in any order).
+}
+
+var cliCases = []cliCase{
+	{
+		name:   "csv",
+		args:   []string{"2022.html"},
+		stderr: []string{`"2022.html": munged successfully`},
+	},
+	{
+		name:   "csv-output-per-input",
+		args:   []string{"-o", "{basename}-sane.csv", "2022.html", "2023.html"},
+		stderr: []string{`saved to "2022-sane.csv"`, `saved to "2023-sane.csv"`},
+	},
+	{
+		name: "csv-confidence-columns",
+		args: []string{"--confidence-columns", "2022.html"},
+	},
+	{
+		name: "csv-numeric",
+		args: []string{"--numeric", "--dates=iso", "2023.html"},
+	},
+	{
+		// The transform's "Jan 2, 2006" dates could be read as dates again, but --dates=iso should leave them be.
+		name: "csv-transform-dates",
+		args: []string{"--transform=Settlement Date:=date:Jan 2, 2006", "--dates=iso", "2022.html"},
+	},
+	{
+		name:   "csv-sort-unreadable-money",
+		args:   []string{"--extra-events=extra-sort.csv", "--sort=Gross Proceeds,desc", "2022.html"},
+		stderr: []string{`W015: event "Broker sale on 01-Dec-2022" has "pending" in "Gross Proceeds"`},
+	},
+	{
+		name: "json",
+		args: []string{"--format=json", "2023.html"},
+	},
+	{
+		name:   "merge",
+		args:   []string{"--merge", "-o", "all.csv", "2022.html", "2023.html"},
+		stderr: []string{`saved to "all.csv"`},
+	},
+	{
+		// Same output as "merge": the order of the files on the command line shouldn't matter.
+		name:   "merge-reversed",
+		args:   []string{"--merge", "-o", "all.csv", "2023.html", "2022.html"},
+		stderr: []string{`saved to "all.csv"`},
+	},
+	{
+		// The metadata should say what's in the output: nothing from 2022.html, and only 2023's events from 2023.html.
+		name: "merge-from-metadata",
+		args: []string{"--merge", "--from=2023-01-01", "--metadata-file=m.txt", "2022.html", "2023.html"},
+	},
+	{
+		name: "merge-keep-duplicates",
+		args: []string{"--merge", "--keep-duplicates", "2022.html", "2023.html"},
+	},
+	{
+		name:   "split-by-year",
+		args:   []string{"--merge", "--split-by=year", "-o", "shareworks.csv", "2022.html", "2023.html"},
+		stderr: []string{`saved to "shareworks-2022.csv"`, `saved to "shareworks-2023.csv"`},
+	},
+	{
+		name: "split-by-schedule",
+		args: []string{"--split-by=schedule", "-o", "{basename}.csv", "2023.html"},
+	},
+	{
+		// The first sale's net is $10 short of its gross less fees, and the second's gross is $60 more than the shares times the price.
+		name: "audit",
+		args: []string{"--audit-columns", "audit.html"},
+	},
+	{
+		name: "audit-breakdown-prefixes",
+		args: []string{"--audit-columns", "--breakdown-prefixes=on", "audit.html"},
+	},
+	{
+		name: "schedule-glob",
+		args: []string{"--schedule=*rsu*", "--schedule=Phantom*", "2022.html"},
+	},
+	{
+		name: "beancount",
+		args: []string{"--merge", "--format=beancount", "--journal-config=journal-config.json", "2022.html", "2023.html"},
+	},
+	{
+		name: "ledger",
+		args: []string{"--merge", "--format=ledger", "--journal-config=journal-config.json", "2022.html", "2023.html"},
+	},
+	{
+		name: "ledger-breakdown-prefixes",
+		args: []string{"--merge", "--format=ledger", "--journal-config=journal-config.json", "--breakdown-prefixes=on", "2022.html", "2023.html"},
+	},
+	{
+		name: "beancount-from",
+		args: []string{"--merge", "--format=beancount", "--journal-config=journal-config.json", "--from=2023-01-01", "2022.html", "2023.html"},
+	},
+	{
+		name: "ledger-split-by-year",
+		args: []string{"--merge", "--format=ledger", "--journal-config=journal-config.json", "--split-by=year", "-o", "shareworks.ledger", "2022.html", "2023.html"},
+	},
+	{
+		name: "beancount-no-release",
+		args: []string{"--format=beancount", "--journal-config=journal-config.json", "2023.html"},
+	},
+	{
+		name: "qif",
+		args: []string{"--merge", "--format=qif", "--journal-config=journal-config.json", "2022.html", "2023.html"},
+	},
+	{
+		name:   "not-a-statement",
+		args:   []string{"not-a-statement.html", "2022.html"},
+		exit:   14,
+		stderr: []string{`"not-a-statement.html": failed`, `"2022.html": munged successfully`},
+	},
+	{
+		name:   "unknown-format",
+		args:   []string{"--format=xml", "2022.html"},
+		exit:   14,
+		stderr: []string{`unsupported --format value "xml"`},
+	},
+	{
+		name:   "collapse-journal",
+		args:   []string{"--same-day-sales=collapse", "--format=ledger", "2022.html"},
+		exit:   14,
+		stderr: []string{"--same-day-sales=collapse doesn't work with --format=ledger"},
+	},
+	{
+		name:   "output-zstd",
+		args:   []string{"--output=out.csv.zst", "2022.html"},
+		exit:   14,
+		stderr: []string{`--output "out.csv.zst" ends in .zst, but zstd isn't supported`},
+	},
+	{
+		name:   "output-gz-uncompressed",
+		args:   []string{"--output=out.csv.gz", "2022.html"},
+		exit:   14,
+		stderr: []string{`--output "out.csv.gz" ends in .gz, so it should be gzipped: add --compress=gzip`},
+	},
+	{
+		name:   "split-by-needs-output",
+		args:   []string{"--split-by=year", "2022.html"},
+		exit:   14,
+		stderr: []string{"--split-by writes several files"},
+	},
+}
+
+func TestCLI(t *testing.T) {
+	for _, tc := range cliCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			got, code, stderr := runCli(t, tc.args)
+			if code != tc.exit {
+				t.Errorf("exit code %d, want %d; stderr:\n%s", code, tc.exit, stderr)
+			}
+			for _, want := range tc.stderr {
+				if !strings.Contains(stderr, want) {
+					t.Errorf("stderr doesn't say %q; it's:\n%s", want, stderr)
+				}
+			}
+			compareOutputs(t, filepath.Join("testdata", "cli", tc.name), got)
+		})
+	}
+}
+
+// TestCLIUnchanged checks that munging the same file into the same output file twice leaves it alone the second time.
+func TestCLIUnchanged(t *testing.T) {
+	for _, format := range []string{"csv", "json", "ics"} {
+		work := fixtureDir(t)
+		args := []string{"--format=" + format, "-o", "out", "2023.html"}
+		if _, code, stderr := runCliIn(t, work, args); code != 0 {
+			t.Fatalf("%s: exit code %d; stderr:\n%s", format, code, stderr)
+		}
+		_, code, stderr := runCliIn(t, work, args)
+		if code != 0 {
+			t.Fatalf("%s: exit code %d the second time; stderr:\n%s", format, code, stderr)
+		}
+		if !strings.Contains(stderr, `"out" is unchanged`) {
+			t.Errorf("%s: the second run didn't leave the output unchanged; stderr:\n%s", format, stderr)
+		}
+	}
+}
+
+// runCli runs the binary in a fresh copy of testdata/statements, and returns what it wrote:
+// its stdout (as "stdout", if there was any), and every file it made, by path relative to where it ran.
+func runCli(t *testing.T, args []string) (outputs map[string][]byte, code int, stderr string) {
+	t.Helper()
+	work := fixtureDir(t)
+	stdout, code, stderr := runCliIn(t, work, args)
+	outputs = map[string][]byte{}
+	if len(stdout) > 0 {
+		outputs["stdout"] = stdout
+	}
+	fixtures := map[string]bool{}
+	for _, name := range fixtureNames(t) {
+		fixtures[name] = true
+	}
+	err := filepath.Walk(work, func(path string, fi os.FileInfo, err error) error {
+		if err != nil || fi.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(work, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if fixtures[rel] {
+			return nil
+		}
+		outputs[rel], err = ioutil.ReadFile(path)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return outputs, code, stderr
+}
+
+func runCliIn(t *testing.T, dir string, args []string) (stdout []byte, code int, stderr string) {
+	t.Helper()
+	var outBuf, errBuf bytes.Buffer
+	cmd := exec.Command(binary, args...)
+	cmd.Dir = dir
+	cmd.Stdout, cmd.Stderr = &outBuf, &errBuf
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+	case errors.As(err, &exitErr):
+		code = exitErr.ExitCode()
+	default:
+		t.Fatal(err)
+	}
+	return outBuf.Bytes(), code, errBuf.String()
+}
+
+func fixtureNames(t *testing.T) []string {
+	t.Helper()
+	infos, err := ioutil.ReadDir(filepath.Join("testdata", "statements"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, fi := range infos {
+		names = append(names, fi.Name())
+	}
+	return names
+}
+
+// fixtureDir makes a temporary directory with a copy of every file in testdata/statements in it.
+func fixtureDir(t *testing.T) string {
+	t.Helper()
+	work := t.TempDir()
+	for _, name := range fixtureNames(t) {
+		bs, err := ioutil.ReadFile(filepath.Join("testdata", "statements", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		dest := filepath.Join(work, name)
+		if err := ioutil.WriteFile(dest, bs, 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(dest, fixtureModTime, fixtureModTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return work
+}
+
+// compareOutputs checks that got is exactly the files in dir: no more, no fewer, and the same content.
+// With -update, it makes dir match got instead.
+func compareOutputs(t *testing.T, dir string, got map[string][]byte) {
+	t.Helper()
+	if *update {
+		if err := os.RemoveAll(dir); err != nil {
+			t.Fatal(err)
+		}
+		for name, content := range got {
+			path := filepath.Join(dir, filepath.FromSlash(name))
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := ioutil.WriteFile(path, content, 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return
+	}
+	want := map[string][]byte{}
+	err := filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil || fi.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		want[filepath.ToSlash(rel)], err = ioutil.ReadFile(path)
+		return err
+	})
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+	var names []string
+	for name := range want {
+		names = append(names, name)
+	}
+	for name := range got {
+		if _, ok := want[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		wantContent, wanted := want[name]
+		gotContent, gotten := got[name]
+		switch {
+		case !gotten:
+			t.Errorf("%s: expected, but not written", name)
+		case !wanted:
+			t.Errorf("%s: written, but not expected:\n%s", name, gotContent)
+		case !bytes.Equal(gotContent, wantContent):
+			t.Errorf("%s: differs from %s: %s", name, filepath.Join(dir, name), firstDifference(gotContent, wantContent))
+		}
+	}
+}
+
+// firstDifference says where got first differs from want.
+// If want reads as CSV (every row as wide as the header), that's by row and column, counting the header as row 1 like a spreadsheet would; otherwise it's by line.
+func firstDifference(got, want []byte) string {
+	gotRows, gotErr := readAllCSV(got, -1)
+	wantRows, wantErr := readAllCSV(want, 0)
+	if gotErr == nil && wantErr == nil && len(wantRows) > 0 && len(wantRows[0]) > 1 {
+		header := wantRows[0]
+		for i := 0; i < len(gotRows) || i < len(wantRows); i++ {
+			switch {
+			case i >= len(gotRows):
+				return fmt.Sprintf("row %d is missing; want %q", i+1, wantRows[i])
+			case i >= len(wantRows):
+				return fmt.Sprintf("row %d is extra: %q", i+1, gotRows[i])
+			}
+			for j := 0; j < len(gotRows[i]) || j < len(wantRows[i]); j++ {
+				var g, w string
+				if j < len(gotRows[i]) {
+					g = gotRows[i][j]
+				}
+				if j < len(wantRows[i]) {
+					w = wantRows[i][j]
+				}
+				if g == w && j < len(gotRows[i]) && j < len(wantRows[i]) {
+					continue
+				}
+				column := fmt.Sprintf("column %d", j+1)
+				if j < len(header) {
+					column = fmt.Sprintf("column %d (%q)", j+1, header[j])
+				}
+				return fmt.Sprintf("row %d, %s: got %q, want %q\n--- got row:\n%q\n--- want row:\n%q", i+1, column, g, w, gotRows[i], wantRows[i])
+			}
+		}
+		// Same fields, so it's down to quoting or line endings.
+	}
+	gotLines := strings.Split(string(got), "\n")
+	wantLines := strings.Split(string(want), "\n")
+	for i := 0; i < len(gotLines) || i < len(wantLines); i++ {
+		switch {
+		case i >= len(gotLines):
+			return fmt.Sprintf("line %d is missing; want %q", i+1, wantLines[i])
+		case i >= len(wantLines):
+			return fmt.Sprintf("line %d is extra: %q", i+1, gotLines[i])
+		case gotLines[i] != wantLines[i]:
+			return fmt.Sprintf("line %d:\n--- got:\n%q\n--- want:\n%q", i+1, gotLines[i], wantLines[i])
+		}
+	}
+	return "same lines, different bytes"
+}
+
+// readAllCSV reads content as CSV, with fieldsPerRecord as in csv.Reader: 0 for every row as wide as the first, -1 for any widths.
+func readAllCSV(content []byte, fieldsPerRecord int) ([][]string, error) {
+	r := csv.NewReader(bytes.NewReader(content))
+	r.FieldsPerRecord = fieldsPerRecord
+	return r.ReadAll()
+}
+
+// TestLedgerBalances checks that every transaction in the expected ledger outputs balances, the way hledger balances them:
+// each posting's amount counts at its "@" price, if it has one, and any "{}" lot cost is ignored.  (Postings with no amount are left for ledger to fill in, so those transactions are skipped.)
+func TestLedgerBalances(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "cli", "ledger*", "*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	posting := regexp.MustCompile(`^\s+\S+\s{2,}(-?[\d.]+) ("[^"]*"|\S+)(?: \{[^}]*\})?(?: @ (-?[\d.]+) (\S+))?$`)
+	for _, file := range files {
+		bs, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, tx := range strings.Split(string(bs), "\n\n") {
+			lines := strings.Split(strings.TrimSpace(tx), "\n")
+			if len(lines) < 2 || strings.HasPrefix(lines[0], ";") {
+				continue
+			}
+			sums := map[string]float64{}
+			complete := true
+			for _, line := range lines[1:] {
+				if i := strings.Index(line, ";"); i >= 0 {
+					line = strings.TrimRight(line[:i], " ")
+				}
+				if strings.TrimSpace(line) == "" {
+					continue
+				}
+				m := posting.FindStringSubmatch(line)
+				if m == nil {
+					complete = false
+					break
+				}
+				amount, _ := strconv.ParseFloat(m[1], 64)
+				commodity := m[2]
+				if m[3] != "" {
+					price, _ := strconv.ParseFloat(m[3], 64)
+					amount, commodity = amount*price, m[4]
+				}
+				sums[commodity] += amount
+			}
+			if !complete {
+				continue
+			}
+			for commodity, sum := range sums {
+				if math.Abs(sum) > 0.005 {
+					t.Errorf("%s: %q is off by %.2f %s", file, lines[0], sum, commodity)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// With --merge, all the input files go into one output, instead of one output each.
+// That's what you want for a pile of yearly statements: one header row, one chronological list.
+// Each row gets a "Source File" column saying which statement it came from.
+
+const sourceFileColumn = "Source File"
+
+// inputBatch is a set of entries that get processed and emitted together:
+// either one input file, or (with --merge) all of them.
+type inputBatch struct {
+	source   string // the input filename, or a list of the merged ones.
+	name     string // what output filenames are based on: the input filename, or "merged" next to the first input.
+	merged   bool
+	modified time.Time // when the input file was last changed (the latest of them, if merged); see inputModTime.
+	columns  []string
+	entries  []map[string]string
+}
+
+// combineEntries unions the columns and entries of several input files, in chronological order.
+// The result doesn't depend on what order the files were given in: the files are put in order first (see orderFiles),
+// and entries on the same date stay in that order, and in the order they were in within their own file.
+//
+// Statement periods often overlap (say, one for the calendar year and one for the fiscal year),
+// so an event that's identical to one from an earlier file is only kept once, and its "Source File" lists both files.
+// (Identical events within one file are left alone, though; that's the statement's business.)
+// With keepDuplicates, everything is kept.
+func combineEntries(files []inputBatch, keepDuplicates bool) (columns []string, entries []map[string]string) {
+	files = orderFiles(files)
+	columns = []string{sourceFileColumn}
+	var dates []time.Time
+	for _, file := range files {
+		for _, col := range file.columns {
+			found := false
+			for _, existing := range columns {
+				if existing == col {
+					found = true
+					break
+				}
+			}
+			if !found {
+				columns = append(columns, col)
+			}
+		}
+		earlier := entries
+		fileDates := entryDates(file.entries)
+	entryLoop:
+		for i, ent := range file.entries {
+			if !keepDuplicates {
+				for _, prev := range earlier {
+					if sameEntry(prev, ent) {
+						tracef("event %q from %q is also in %q; keeping just the one", ent["Event"], file.source, prev[sourceFileColumn])
+						prev[sourceFileColumn] += ", " + filepath.Base(file.source)
+						continue entryLoop
+					}
+				}
+			}
+			ent[sourceFileColumn] = filepath.Base(file.source)
+			entries = append(entries, ent)
+			dates = append(dates, fileDates[i])
+		}
+	}
+	// Sort a permutation, so the dates can come along, like SortEntries does.
+	//  (SortEntries itself would give an undated event the date of whatever's before it, which here could be from another file.)
+	order := make([]int, len(entries))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return dates[order[i]].Before(dates[order[j]])
+	})
+	sorted := make([]map[string]string, len(entries))
+	for i, idx := range order {
+		sorted[i] = entries[idx]
+	}
+	return columns, sorted
+}
+
+// orderFiles puts input files in a fixed order, whatever order they were given in:
+// by the earliest settlement date in each, and then by name.  Files with no dates at all go last.
+func orderFiles(files []inputBatch) []inputBatch {
+	earliest := map[string]time.Time{}
+	for _, file := range files {
+		for _, date := range entryDates(file.entries) {
+			if !date.IsZero() {
+				earliest[file.source] = date
+				break
+			}
+		}
+	}
+	result := append([]inputBatch(nil), files...)
+	sort.SliceStable(result, func(i, j int) bool {
+		a, b := earliest[result[i].source], earliest[result[j].source]
+		if !a.Equal(b) {
+			return !a.IsZero() && (b.IsZero() || a.Before(b))
+		}
+		if nameA, nameB := filepath.Base(result[i].source), filepath.Base(result[j].source); nameA != nameB {
+			return nameA < nameB
+		}
+		return result[i].source < result[j].source
+	})
+	return result
+}
+
+// entryDates is the settlement date of each of one file's entries (which should already be in order),
+// with the undated ones sorted as if they had the date of the entry before them -- or, at the start of the file, after them.
+// That keeps them next to their neighbors from the same file.  If nothing in the file has a date, it's all zero.
+func entryDates(entries []map[string]string) []time.Time {
+	dates := make([]time.Time, len(entries))
+	var previous time.Time
+	leading := 0
+	for i, ent := range entries {
+		date, err := parseDate(ent["Settlement Date:"])
+		if err != nil {
+			dates[i] = previous
+			if previous.IsZero() {
+				leading++
+			}
+			continue
+		}
+		if previous.IsZero() {
+			for j := 0; j < leading; j++ {
+				dates[j] = date
+			}
+		}
+		dates[i] = date
+		previous = date
+	}
+	return dates
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"runtime/debug"
+	"strings"
+	"time"
+)
+
+// version can be set at build time with `-ldflags "-X main.version=v1.2.3"`.
+// If it isn't, we'll use whatever Go knows about the module version (which, for `go run`, is usually "(devel)").
+var version = ""
+
+func toolVersion() string {
+	if version != "" {
+		return version
+	}
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" {
+		return info.Main.Version
+	}
+	return "unknown"
+}
+
+// runRecord remembers what went into a run, so we can write it down in a metadata file afterwards.
+// The point is that six months from now, it should be obvious what a given csv actually covers.
+type runRecord struct {
+	inputs []inputRecord
+}
+
+type inputRecord struct {
+	filename    string
+	modified    time.Time // when the input was last modified.
+	events      int
+	first, last time.Time // by settlement date.  Zero if there weren't any dates.
+}
+
+func (r *runRecord) add(filename string, modified time.Time, entries []map[string]string) {
+	rec := inputRecord{filename: filename, modified: modified, events: len(entries)}
+	rec.first, rec.last = dateRange(entries)
+	r.inputs = append(r.inputs, rec)
+}
+
+func dateRange(entries []map[string]string) (first, last time.Time) {
+	for _, ent := range entries {
+		date, err := parseDate(ent["Settlement Date:"])
+		if err != nil {
+			continue
+		}
+		if first.IsZero() || date.Before(first) {
+			first = date
+		}
+		if last.IsZero() || date.After(last) {
+			last = date
+		}
+	}
+	return first, last
+}
+
+func formatRecordDate(t time.Time) string {
+	if t.IsZero() {
+		return "(none)"
+	}
+	return t.Format("2006-01-02")
+}
+
+func (r *runRecord) write(filename string) error {
+	var first, last, modified time.Time
+	var sb strings.Builder
+	for _, in := range r.inputs {
+		if in.modified.After(modified) {
+			modified = in.modified
+		}
+		if !in.first.IsZero() && (first.IsZero() || in.first.Before(first)) {
+			first = in.first
+		}
+		if !in.last.IsZero() && (last.IsZero() || in.last.After(last)) {
+			last = in.last
+		}
+	}
+	fmt.Fprintf(&sb, "Generated by: shareworks-munger %s\n", toolVersion())
+	// Not the time of the run: then the file would change every time, even when nothing else did.
+	fmt.Fprintf(&sb, "Newest input modified: %s\n", modified.Format(time.RFC3339))
+	fmt.Fprintf(&sb, "Earliest settlement date: %s\n", formatRecordDate(first))
+	fmt.Fprintf(&sb, "Latest settlement date: %s\n", formatRecordDate(last))
+	fmt.Fprintf(&sb, "Input files:\n")
+	for _, in := range r.inputs {
+		fmt.Fprintf(&sb, "  - %s: %d events, %s to %s\n", in.filename, in.events, formatRecordDate(in.first), formatRecordDate(in.last))
+	}
+	if err := ioutil.WriteFile(filename, []byte(sb.String()), 0644); err != nil {
+		return fmt.Errorf("failed to write metadata file %q: %w", filename, err)
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Internally, the normalized columns are always called by their built-in names ("stocks report", "price per unit").
+// What they're called in the output is up to the user, though:
+// the built-in names are just the fallback profile, and any of them can be renamed on the way out.
+
+// builtinNormalizedNames are the column names normalizeColumnName produces.
+var builtinNormalizedNames = []string{"stocks report", "price per unit"}
+
+// nameProfile maps built-in normalized column names to what they should be called in the output.
+// Names that aren't in the map keep their built-in name.
+type nameProfile map[string]string
+
+// parseNameProfile parses "stocks report=Quantity,price per unit=Price".
+func parseNameProfile(s string) (nameProfile, error) {
+	profile := nameProfile{}
+	if strings.TrimSpace(s) == "" {
+		return profile, nil
+	}
+	for _, pair := range strings.Split(s, ",") {
+		eq := strings.Index(pair, "=")
+		if eq < 0 {
+			return nil, fmt.Errorf("column name %q should look like BUILTIN=NEW", pair)
+		}
+		builtin, renamed := strings.TrimSpace(pair[:eq]), strings.TrimSpace(pair[eq+1:])
+		known := false
+		for _, name := range builtinNormalizedNames {
+			known = known || name == builtin
+		}
+		if !known {
+			return nil, fmt.Errorf("%q isn't one of the normalized column names (%s)", builtin, strings.Join(builtinNormalizedNames, ", "))
+		}
+		if renamed == "" {
+			return nil, fmt.Errorf("column name %q is missing the new name", pair)
+		}
+		profile[builtin] = renamed
+	}
+	return profile, nil
+}
+
+// apply returns copies of the column order and entries with the columns renamed.
+func (p nameProfile) apply(columnOrder []string, entries []map[string]string) ([]string, []map[string]string) {
+	if len(p) == 0 {
+		return columnOrder, entries
+	}
+	renamedColumns := make([]string, len(columnOrder))
+	for i, col := range columnOrder {
+		renamedColumns[i] = p.rename(col)
+	}
+	renamedEntries := make([]map[string]string, len(entries))
+	for i, ent := range entries {
+		row := make(map[string]string, len(ent))
+		for k, v := range ent {
+			row[p.rename(k)] = v
+		}
+		renamedEntries[i] = row
+	}
+	return renamedColumns, renamedEntries
+}
+
+func (p nameProfile) rename(col string) string {
+	if renamed, ok := p[col]; ok {
+		return renamed
+	}
+	return col
+}
+
+// unrename goes the other way, for reading our own output back in.
+func (p nameProfile) unrename(col string) string {
+	for builtin, renamed := range p {
+		if renamed == col {
+			return builtin
+		}
+	}
+	return col
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+)
+
+// normalizationKey is one rename that accumulate did: a label, for a type of event, turned into a column name.
+type normalizationKey struct {
+	eventType  string
+	original   string
+	normalized string
+}
+
+// normalizationsSeen counts every rename done during this run, for --show-normalization.
+var normalizationsSeen = map[normalizationKey]int{}
+
+func noteNormalization(eventType, original, normalized string) {
+	normalizationsSeen[normalizationKey{eventType, original, normalized}]++
+}
+
+// printNormalizationReport writes a table of every label that got renamed to a different column name during this run.
+func printNormalizationReport(wr io.Writer) {
+	var keys []normalizationKey
+	for k := range normalizationsSeen {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].normalized != keys[j].normalized {
+			return keys[i].normalized < keys[j].normalized
+		}
+		if keys[i].eventType != keys[j].eventType {
+			return keys[i].eventType < keys[j].eventType
+		}
+		return keys[i].original < keys[j].original
+	})
+	fmt.Fprintf(wr, "Column normalization for this run:\n")
+	if len(keys) == 0 {
+		fmt.Fprintf(wr, "  (no labels were renamed)\n")
+		return
+	}
+	tw := tabwriter.NewWriter(wr, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "  TYPE\tORIGINAL LABEL\tCOLUMN\tTIMES\n")
+	for _, k := range keys {
+		fmt.Fprintf(tw, "  %s\t%q\t%q\t%d\n", k.eventType, k.original, k.normalized, normalizationsSeen[k])
+	}
+	tw.Flush()
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"regexp"
+	"strconv"
+
+	"github.com/warpfork/shareworks-munger/pkg/shareworks"
+)
+
+// With --numeric, money values like "$1,234.56 USD" become plain numbers like "1234.56",
+// so spreadsheets treat them as numbers instead of text.
+// The currency goes into a column of its own, right after the one it came from ("Gross Proceeds Currency").
+// With --currency-columns, the currency columns are added, but the values are left as they were;
+// that's for statements that mix currencies, where you want it spelled out but still want the original text.
+//
+// Whole columns are converted or not: a column is only converted if every value in it can be read as a number,
+// and at least one of them looks like money (see shareworks.LooksLikeMoney), and not just like a number.
+// (Otherwise a column of dates with one odd value in it would come out half-converted, which is worse than not at all;
+// and a column of IDs like "000123" would lose its leading zeros.)
+// Columns that never had a currency marked on them don't get a currency column.
+// A bare "$" doesn't say whose dollars, so values with only that get an empty currency.
+
+// currencyColumnSuffix is added to a column's name to name its currency column.
+const currencyColumnSuffix = " Currency"
+
+var decimalPlacesPatterns = map[shareworks.NumberFormat]*regexp.Regexp{
+	shareworks.DecimalPoint: regexp.MustCompile(`\.(\d+)`),
+	shareworks.DecimalComma: regexp.MustCompile(`,(\d+)`),
+}
+
+// currencyColumns returns the columns and copies of the entries, with a currency column after every column of money values that has one.
+// If numeric is set, the money values are converted to plain numbers, too.
+// The originals are left alone.
+func currencyColumns(columnOrder []string, entries []map[string]string, numeric bool) ([]string, []map[string]string) {
+	result := make([]map[string]string, len(entries))
+	for i, ent := range entries {
+		row := make(map[string]string, len(ent))
+		for k, v := range ent {
+			row[k] = v
+		}
+		result[i] = row
+	}
+	var columns []string
+	for _, col := range columnOrder {
+		columns = append(columns, col)
+		amounts, currencies, ok := parseNumericColumn(col, entries)
+		if !ok {
+			continue
+		}
+		hasCurrency := false
+		for i, row := range result {
+			if _, present := row[col]; !present {
+				continue
+			}
+			if numeric {
+				row[col] = amounts[i]
+				// The plain numbers always have a decimal point, whatever the statement had.
+				delete(row, shareworks.NumberFormatKey)
+			}
+			if currencies[i] != "" {
+				hasCurrency = true
+			}
+		}
+		if !hasCurrency {
+			continue
+		}
+		currencyCol := col + currencyColumnSuffix
+		columns = append(columns, currencyCol)
+		for i, row := range result {
+			if _, present := entries[i][col]; present {
+				row[currencyCol] = currencies[i]
+			}
+		}
+	}
+	return columns, result
+}
+
+// parseNumericColumn reads every value in a column as money.
+// It's not ok if any of them can't be read, or if none of them look like money, or if the column has no values at all.
+func parseNumericColumn(col string, entries []map[string]string) (amounts, currencies []string, ok bool) {
+	amounts = make([]string, len(entries))
+	currencies = make([]string, len(entries))
+	seen, money := false, false
+	for i, ent := range entries {
+		value := ent[col]
+		if value == "" {
+			continue
+		}
+		amount, currency, err := shareworks.ParseEntryMoney(ent, value)
+		if err != nil {
+			return nil, nil, false
+		}
+		// Keep as many decimal places as the statement had: "$1.00" should stay "1.00", not become "1".
+		decimals := 0
+		if m := decimalPlacesPatterns[shareworks.NumberFormatOf(ent)].FindStringSubmatch(shareworks.StripMoneyDecorations(value)); m != nil {
+			decimals = len(m[1])
+		}
+		amounts[i] = strconv.FormatFloat(amount, 'f', decimals, 64)
+		currencies[i] = currency
+		seen = true
+		money = money || shareworks.LooksLikeMoney(value)
+	}
+	return amounts, currencies, seen && money
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// outputExtension is the file extension for the chosen output format (and compression).
+func outputExtension(opts options) string {
+	ext := map[string]string{
+		"csv":           ".csv",
+		"json":          ".json",
+		"timeline-html": ".html",
+		"ics":           ".ics",
+		"beancount":     ".beancount",
+		"ledger":        ".ledger",
+		"qif":           ".qif",
+		"dot":           ".dot",
+		"graph-json":    ".json",
+	}[opts.format]
+	if opts.compress == "gzip" {
+		ext += ".gz"
+	}
+	return ext
+}
+
+// compressedExtensions are the extensions of compressed files, and what compresses them.
+// Only gzip can be written; the rest are here so an --output named for one of them is turned down, rather than written uncompressed.
+var compressedExtensions = map[string]string{
+	".gz":   "gzip",
+	".zst":  "zstd",
+	".zstd": "zstd",
+	".bz2":  "bzip2",
+	".xz":   "xz",
+	".lz4":  "lz4",
+	".br":   "brotli",
+}
+
+// checkOutputCompression makes sure an --output name that ends like a compressed file gets compressed that way.
+func checkOutputCompression(output string, compress string) error {
+	method, ok := compressedExtensions[strings.ToLower(filepath.Ext(output))]
+	switch {
+	case !ok || method == compress:
+		return nil
+	case method == "gzip":
+		return fmt.Errorf("--output %q ends in %s, so it should be gzipped: add --compress=gzip", output, filepath.Ext(output))
+	default:
+		return fmt.Errorf("--output %q ends in %s, but %s isn't supported -- only gzip is (--compress=gzip, and a name ending in .gz)", output, filepath.Ext(output), method)
+	}
+}
+
+// outputFilename works out where the output for an input file goes, by filling in the --output template.
+// "{basename}" in the template is replaced with the input's filename, minus its directory and extension.
+// For stdin, it's "stdin".
+func outputFilename(template string, input string) string {
+	basename := strings.TrimSuffix(filepath.Base(input), filepath.Ext(input))
+	if input == stdinFilename {
+		basename = "stdin"
+	}
+	return strings.ReplaceAll(template, "{basename}", safeFilename(basename))
+}
+
+// safeFilename makes a name (of an input file, or anything else that ends up in an output filename) safe to use as a filename on any OS.
+// Windows is the picky one: no <>:"/\|?* or control characters, no trailing dots or spaces, and no device names like "CON" or "NUL".
+func safeFilename(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if r < 0x20 || strings.ContainsRune(`<>:"/\|?*`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+	name = strings.TrimRight(name, ". ")
+	if name == "" {
+		return "_"
+	}
+	stem := strings.ToUpper(strings.SplitN(name, ".", 2)[0])
+	switch stem {
+	case "CON", "PRN", "AUX", "NUL",
+		"COM1", "COM2", "COM3", "COM4", "COM5", "COM6", "COM7", "COM8", "COM9",
+		"LPT1", "LPT2", "LPT3", "LPT4", "LPT5", "LPT6", "LPT7", "LPT8", "LPT9":
+		name = "_" + name
+	}
+	return name
+}
+
+// outputNames hands out output filenames, making sure no two outputs in a run get the same one
+// (say, two inputs both called "statement.html", from different directories).
+// Filesystems on Windows and macOS usually ignore case, so "Wow.csv" and "wow.csv" count as the same.
+// A clash gets a number added: "wow.csv", then "wow-2.csv", "wow-3.csv", and so on, in the order the inputs were given.
+type outputNames map[string]bool
+
+func (used outputNames) claim(filename string) string {
+	stem, ext := splitExtension(filename)
+	candidate := filename
+	for n := 2; used[strings.ToLower(candidate)]; n++ {
+		candidate = fmt.Sprintf("%s-%d%s", stem, n, ext)
+	}
+	used[strings.ToLower(candidate)] = true
+	return candidate
+}
+
+// writeOutputFile emits into a file (compressing it, if --compress says so).
+// If the file is already there with exactly the same content, it's left alone (and unchanged is true),
+// which is friendlier to sync folders and anything watching modification times.
+// If it's there with different content and keepBackup is set, the old one is kept with a ".bak" suffix.
+func writeOutputFile(filename string, opts options, src emitSource, columnOrder []string, entries []map[string]string) (unchanged bool, err error) {
+	var buf bytes.Buffer
+	var wr io.Writer = &buf
+	if opts.compress == "gzip" {
+		wr = gzip.NewWriter(&buf)
+	}
+	if err := emit(wr, opts, src, columnOrder, entries); err != nil {
+		return false, err
+	}
+	if gz, ok := wr.(*gzip.Writer); ok {
+		if err := gz.Close(); err != nil {
+			return false, err
+		}
+	}
+	existing, err := ioutil.ReadFile(filename)
+	switch {
+	case err == nil && bytes.Equal(existing, buf.Bytes()):
+		return true, nil
+	case err == nil && opts.keepBackup:
+		if err := os.Rename(filename, filename+".bak"); err != nil {
+			return false, fmt.Errorf("failed to keep a backup of output file %q: %w", filename, err)
+		}
+	case err != nil && !os.IsNotExist(err):
+		return false, fmt.Errorf("failed to check existing output file %q: %w", filename, err)
+	}
+	if err := ioutil.WriteFile(filename, buf.Bytes(), 0644); err != nil {
+		return false, fmt.Errorf("failed to write output file %q: %w", filename, err)
+	}
+	return false, nil
+}
@@ -0,0 +1,70 @@
+package shareworks
+
+import (
+	"fmt"
+	"strings"
+)
+
+// The tables after an event's main table break its values down further: "Value of Shares Sold", "Sale Breakdown", "Net Proceeds", and so on.
+// By default their line items become columns under their own labels ("Commission"), and their totals get named after the table ("Sale Breakdown Total").
+// That's how it's always been, so it stays the default.  But it means related columns end up scattered all over a spreadsheet,
+// and a "Commission" from one table is indistinguishable from a "Commission" from another.
+//
+// With --breakdown-prefixes (WithBreakdownPrefixes, for the library), every column from a breakdown table is prefixed with the table's name instead:
+// "Sale Breakdown: Commission", "Sale Breakdown: Total".  The prefix for any table can be overridden, too,
+// as "TABLE=PREFIX,TABLE=PREFIX".  Normalized columns ("stocks report", "price per unit") are never prefixed;
+// other things depend on finding them under those names.
+
+// BreakdownPrefixes says how to name the columns from breakdown tables.  The zero value is the usual naming, with no prefixes.
+type BreakdownPrefixes struct {
+	enabled bool
+	names   map[string]string // table name -> prefix, where it isn't just the table name.
+}
+
+// ParseBreakdownPrefixes parses a --breakdown-prefixes value: "" for off, "on" for table names, or "TABLE=PREFIX,..." for overrides.
+func ParseBreakdownPrefixes(s string) (BreakdownPrefixes, error) {
+	p := BreakdownPrefixes{names: map[string]string{}}
+	switch strings.TrimSpace(s) {
+	case "", "off":
+		return p, nil
+	case "on":
+		p.enabled = true
+		return p, nil
+	}
+	p.enabled = true
+	for _, pair := range strings.Split(s, ",") {
+		eq := strings.Index(pair, "=")
+		if eq < 0 {
+			return p, fmt.Errorf("breakdown prefix %q should look like TABLE=PREFIX", pair)
+		}
+		table, prefix := strings.TrimSpace(pair[:eq]), strings.TrimSpace(pair[eq+1:])
+		if table == "" || prefix == "" {
+			return p, fmt.Errorf("breakdown prefix %q should look like TABLE=PREFIX", pair)
+		}
+		p.names[table] = prefix
+	}
+	return p, nil
+}
+
+func (p BreakdownPrefixes) prefix(table string) string {
+	if name, ok := p.names[table]; ok {
+		return name
+	}
+	return table
+}
+
+// Column is what a line item from a breakdown table should be called.
+func (p BreakdownPrefixes) Column(table, key, eventType string) string {
+	if !p.enabled || NormalizeColumnName(key, eventType) != key {
+		return key
+	}
+	return p.prefix(table) + ": " + key
+}
+
+// Total is what a breakdown table's total should be called.  The usual name is what it's called when prefixes are off.
+func (p BreakdownPrefixes) Total(table, usual string) string {
+	if !p.enabled {
+		return usual
+	}
+	return p.prefix(table) + ": Total"
+}
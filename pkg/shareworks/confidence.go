@@ -0,0 +1,30 @@
+package shareworks
+
+// Every parsed event gets a "Confidence" column, saying how much we trust the parse:
+//   - "high": the tables were exactly what we expected.
+//   - "medium": the regular parser handled it, but something was a bit off (there'll be a "Confidence Note" saying what).
+//   - "low": the positional fallback parser made it up from a table it didn't recognize.
+//
+// Anything less than "high" deserves a look from a human.
+const (
+	ConfidenceHigh   = "high"
+	ConfidenceMedium = "medium"
+	ConfidenceLow    = "low"
+)
+
+var confidenceRank = map[string]int{
+	ConfidenceLow:    0,
+	ConfidenceMedium: 1,
+	ConfidenceHigh:   2,
+}
+
+// Doubt lowers the confidence of a row (if it isn't already lower), and records why.
+func Doubt(columns *[]string, row Entry, level string, note string) {
+	if current, ok := row["Confidence"]; !ok || confidenceRank[level] < confidenceRank[current] {
+		Accumulate(columns, row, "Confidence", level)
+	}
+	if existing := row["Confidence Note"]; existing != "" {
+		note = existing + "; " + note
+	}
+	Accumulate(columns, row, "Confidence Note", note)
+}
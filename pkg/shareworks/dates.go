@@ -0,0 +1,56 @@
+package shareworks
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Statements mostly write dates like "02-Jan-2006", but not always: some mix in "Jan 2, 2006" or "2006/01/02" in some of their tables.
+// So dates are read by trying a list of layouts in order, and the first that fits wins.
+// The layouts are written the way Go does it: as the date 2006-01-02 would look.
+
+// DateLayouts is an ordered list of date layouts to try.
+type DateLayouts []string
+
+// DefaultDateLayouts are the layouts statements have been seen using, plus ISO 8601, so csv written with the dates rewritten can be read back in.
+var DefaultDateLayouts = DateLayouts{
+	"02-Jan-2006",
+	"Jan 2, 2006",
+	"2006/01/02",
+	"2006-01-02",
+}
+
+// Parse parses a date with the first layout that fits it.  The error, if none do, is from the first layout.
+func (l DateLayouts) Parse(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	var firstErr error
+	for _, layout := range l {
+		t, err := time.Parse(layout, s)
+		if err == nil {
+			return t, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	if firstErr == nil {
+		return time.Time{}, fmt.Errorf("no date layouts to parse %q with", s)
+	}
+	return time.Time{}, firstErr
+}
+
+// ParseStatementDate parses dates the way the statements write them, e.g. "02-Jan-2006", or any of the other DefaultDateLayouts.
+func ParseStatementDate(s string) (time.Time, error) {
+	return DefaultDateLayouts.Parse(s)
+}
+
+// CheckDateLayout returns an error if a layout can't be used to read dates: if it's missing the year, the month, or the day.
+func CheckDateLayout(layout string) error {
+	sample := time.Date(2023, time.November, 25, 0, 0, 0, 0, time.UTC)
+	t, err := time.Parse(layout, sample.Format(layout))
+	if err != nil || !t.Equal(sample) {
+		return fmt.Errorf("date layout %q needs a year, a month, and a day in it, written as they'd be for 2006-01-02", layout)
+	}
+	return nil
+}
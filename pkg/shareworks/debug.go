@@ -0,0 +1,56 @@
+package shareworks
+
+// With WithDebug, Parse also records what it saw for each event before any of it was normalized:
+// every table that went into the event, and the label/value pairs in it, exactly as they were in the statement.
+// It's for tests and tools that want to check the parse itself, rather than what came out of it;
+// and for figuring out what went wrong, when something did.
+
+// DebugEvent is what the parser saw for one event.
+type DebugEvent struct {
+	Title    string       `json:"title"`    // the event's title, as in the "Event" column.
+	Schedule string       `json:"schedule"` // the distribution schedule heading it came under.
+	Tables   []DebugTable `json:"tables"`   // the main table first, then any breakdown and total tables after it.
+}
+
+// DebugTable is one table of an event.
+type DebugTable struct {
+	Title string      `json:"title"` // the table's heading, like "Sale Breakdown".  The main table's is the event title; total tables have none.
+	Pairs []DebugPair `json:"pairs"`
+}
+
+// DebugPair is one label and its value, before normalization (so "Shares Sold:", not "stocks report").
+// The fallback parser has no labels, so its pairs have none either: just the cells, in order.
+type DebugPair struct {
+	Label string `json:"label"`
+	Value string `json:"value"`
+}
+
+// debugEvent starts recording a new event.
+func (p *parser) debugEvent(title, schedule string) {
+	if !p.debug {
+		return
+	}
+	p.debugEvents = append(p.debugEvents, DebugEvent{Title: title, Schedule: schedule})
+}
+
+// debugTable starts recording a new table of the current event.
+func (p *parser) debugTable(title string) {
+	if !p.debug || len(p.debugEvents) == 0 {
+		return
+	}
+	ev := &p.debugEvents[len(p.debugEvents)-1]
+	ev.Tables = append(ev.Tables, DebugTable{Title: title})
+}
+
+// debugPair records a label and value in the current table.
+func (p *parser) debugPair(label, value string) {
+	if !p.debug || len(p.debugEvents) == 0 {
+		return
+	}
+	ev := &p.debugEvents[len(p.debugEvents)-1]
+	if len(ev.Tables) == 0 {
+		return
+	}
+	table := &ev.Tables[len(ev.Tables)-1]
+	table.Pairs = append(table.Pairs, DebugPair{label, value})
+}
@@ -0,0 +1,61 @@
+package shareworks
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// This is the last resort, for when Shareworks changes their html so much that none of our selectors match anything.
+// Rather than produce nothing at all, we go looking for *any* table that has something like a date in it
+// and something like a money amount in it, and dump all its cells, in order, into columns named "Unknown 1", "Unknown 2", etc.
+// It's ugly, and you'll have to sort it out by hand, but at least there's something to review.
+// (And please do file a bug so the real parser can get fixed!)
+
+var (
+	fallbackDatePattern  = regexp.MustCompile(`\b(\d{1,2}-[A-Za-z]{3}-\d{4}|\d{4}[-/]\d{1,2}[-/]\d{1,2}|[A-Za-z]{3,9} \d{1,2}, \d{4})\b`)
+	fallbackMoneyPattern = regexp.MustCompile(`([$€£]|\b(USD|CAD|EUR|GBP)\b)\s*-?[\d,]+(\.\d+)?|-?[\d,]+\.\d{2}\b`)
+)
+
+// mungePositional is the fallback parser.  It returns no entries (and no error) if it found nothing either;
+// the caller should report whatever it was that made it resort to this in the first place.
+func (p *parser) mungePositional(doc *goquery.Document) (columns []string, entries []Entry) {
+	tableCount := 0
+	doc.Find("table").Each(func(i int, table *goquery.Selection) {
+		// Only look at innermost tables; layout tables full of other tables would just be everything, duplicated.
+		if table.Find("table").Length() > 0 {
+			return
+		}
+		var cells []string
+		hasDate, hasMoney := false, false
+		table.Find("td, th").Each(func(_ int, cell *goquery.Selection) {
+			text := strings.TrimSpace(cell.Text())
+			if text == "" {
+				return
+			}
+			cells = append(cells, text)
+			hasDate = hasDate || fallbackDatePattern.MatchString(text)
+			hasMoney = hasMoney || fallbackMoneyPattern.MatchString(text)
+		})
+		if !hasDate || !hasMoney {
+			p.log.Tracef("positional fallback: skipping table %d: no date and money cells", i)
+			return
+		}
+		tableCount++
+		p.log.Tracef("positional fallback: using table %d", i)
+		row := Entry{}
+		entries = append(entries, row)
+		p.accumulate(&columns, row, "Event", fmt.Sprintf("Unrecognized table %d", tableCount))
+		p.debugEvent(row["Event"], "")
+		p.debugTable("")
+		p.accumulate(&columns, row, "Type", "Unknown")
+		Doubt(&columns, row, ConfidenceLow, "positional fallback parser; cells could be anything")
+		for j, text := range cells {
+			p.debugPair("", text)
+			p.accumulate(&columns, row, fmt.Sprintf("Unknown %d", j+1), text)
+		}
+	})
+	return columns, entries
+}
@@ -0,0 +1,37 @@
+package shareworks
+
+import (
+	"github.com/PuerkitoBio/goquery"
+)
+
+// TableHandler parses a kind of event table the parser doesn't know about: a company-specific plan, say.
+//
+// Match is given the title of every event table the parser would otherwise skip (the text of its "newReportTitleStyle" header cell),
+// and says whether this handler wants it.
+// If it does, the table becomes an entry, just as a release or a withdrawal does:
+// it gets the "Distribution Schedule", "Event", "Event Date", "Event Description", and "Confidence" columns the parser always fills in,
+// and then Extract is called with the table, to add the rest with add.
+// add takes a label and a value, and files them the same way the parser does its own (normalized names, the column order, the debug output).
+// Add "Type" first ("Buy", "Sell", or whatever suits), if you want the labels normalized the way that kind of event's are.
+// The tables after this one are table.Next() and on, if the event has breakdowns of its own.
+type TableHandler struct {
+	Match   func(title string) bool
+	Extract func(table *goquery.Selection, add func(label, value string))
+}
+
+// WithTableHandler has Parse use h for the event tables it matches.  See TableHandler.
+// It can be given more than once; the first handler to match a table gets it.
+// The tables the parser knows (releases, withdrawals, dividends) are always parsed the usual way, and never offered to handlers.
+func WithTableHandler(h TableHandler) Option {
+	return func(c *config) { c.tableHandlers = append(c.tableHandlers, h) }
+}
+
+// tableHandler returns the first handler that wants the table with this title, or nil.
+func (p *parser) tableHandler(title string) *TableHandler {
+	for i, h := range p.tableHandlers {
+		if h.Match(title) {
+			return &p.tableHandlers[i]
+		}
+	}
+	return nil
+}
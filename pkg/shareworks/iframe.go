@@ -0,0 +1,73 @@
+package shareworks
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// The statement lives inside an iframe, and the most common mistake is saving the page around it instead of what's inside.
+// Often that's still salvageable, though:
+//   - some browsers inline the iframe's document into a "srcdoc" attribute when saving;
+//   - "Save Page As... (complete)" puts the iframe's document in a "NAME_files" directory next to the html,
+//     and points the iframe's "src" at it.
+// So before complaining, we go looking in those places.
+
+const statementIframeSelector = "iframe#transaction-statement-iframe"
+
+// innerStatementDocument returns the document inside the statement iframe, if doc is the enclosing page and the inside can be found.
+// If doc isn't the enclosing page at all, it's returned as-is.
+// The "_files" directory is only looked for with WithSidecarFiles, and if the filename is of a regular file; otherwise there's nothing for it to be next to.
+func (p *parser) innerStatementDocument(doc *goquery.Document) (*goquery.Document, error) {
+	filename := p.filename
+	iframe := doc.Find(statementIframeSelector).First()
+	if iframe.Length() == 0 {
+		return doc, nil
+	}
+	if srcdoc, ok := iframe.Attr("srcdoc"); ok && strings.TrimSpace(srcdoc) != "" {
+		p.log.Infof("%s: this is the enclosing page, but the statement is saved inside the iframe, so using that.", p.name())
+		return goquery.NewDocumentFromReader(strings.NewReader(srcdoc))
+	}
+	if p.sidecarFiles {
+		if fi, err := os.Stat(filename); err == nil && fi.Mode().IsRegular() {
+			for _, candidate := range iframeDocumentCandidates(filename, iframe) {
+				bs, err := ioutil.ReadFile(candidate)
+				if err != nil {
+					continue
+				}
+				inner, err := goquery.NewDocumentFromReader(bytes.NewReader(bs))
+				if err != nil || inner.Find("table.sw-datatable").Length() == 0 {
+					p.log.Tracef("%s: iframe document candidate %q has no statement tables", p.name(), candidate)
+					continue
+				}
+				p.log.Infof("%s: this is the enclosing page, but the statement was saved alongside it in %q, so using that.", p.name(), candidate)
+				return inner, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("wrong html -- it looks like you got the enclosing document.  Check the README again -- did you do extraction correctly?  You have to get the content from inside the iframe element.  (Sorry this is complicated.  I didn't write the website.)")
+}
+
+// iframeDocumentCandidates lists the files that might hold the iframe's document, most likely first:
+// wherever its src points, and then every html file in the "_files" directory next to the page.
+func iframeDocumentCandidates(filename string, iframe *goquery.Selection) []string {
+	dir := filepath.Dir(filename)
+	var candidates []string
+	if src, ok := iframe.Attr("src"); ok {
+		if u, err := url.Parse(src); err == nil && u.Scheme == "" && u.Host == "" && u.Path != "" {
+			candidates = append(candidates, filepath.Join(dir, filepath.FromSlash(u.Path)))
+		}
+	}
+	filesDir := strings.TrimSuffix(filename, filepath.Ext(filename)) + "_files"
+	for _, pattern := range []string{"*.html", "*.htm"} {
+		matches, _ := filepath.Glob(filepath.Join(filesDir, pattern))
+		candidates = append(candidates, matches...)
+	}
+	return candidates
+}
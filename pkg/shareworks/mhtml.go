@@ -0,0 +1,93 @@
+package shareworks
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"strings"
+)
+
+// MHTML is what Chrome and Edge produce with "Save as... Webpage, Single File": the page and everything in it, as one MIME message.
+// Handily, each frame is saved as a part of its own, so the statement is just sitting there in one of the parts.
+// We find the html part that has statement tables in it, and hand that to the usual parser.
+
+// IsMhtmlFilename reports whether a filename looks like an MHTML save.
+func IsMhtmlFilename(filename string) bool {
+	lower := strings.ToLower(filename)
+	return strings.HasSuffix(lower, ".mht") || strings.HasSuffix(lower, ".mhtml")
+}
+
+// looksLikeMhtml sniffs content (for stdin, or anything else where there's no filename to go by).
+func looksLikeMhtml(bs []byte) bool {
+	header := bs
+	if i := bytes.Index(bs, []byte("\n\n")); i >= 0 {
+		header = bs[:i]
+	} else if i := bytes.Index(bs, []byte("\r\n\r\n")); i >= 0 {
+		header = bs[:i]
+	}
+	return bytes.Contains(bytes.ToLower(header), []byte("multipart/related"))
+}
+
+// htmlFromMhtml returns the html of the part of an MHTML file that holds the statement.
+// If no part has statement tables, it returns the first html part (which is probably the enclosing page, and will get complained about as such).
+func (p *parser) htmlFromMhtml(bs []byte) ([]byte, error) {
+	msg, err := mail.ReadMessage(bytes.NewReader(bs))
+	if err != nil {
+		return nil, fmt.Errorf("not a readable MHTML file: %w", err)
+	}
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || !strings.HasPrefix(mediaType, "multipart/") || params["boundary"] == "" {
+		return nil, fmt.Errorf("not a readable MHTML file: expected a multipart message, got %q", msg.Header.Get("Content-Type"))
+	}
+	var first []byte
+	mr := multipart.NewReader(msg.Body, params["boundary"])
+	for i := 0; ; i++ {
+		part, err := mr.NextRawPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("not a readable MHTML file: %w", err)
+		}
+		partType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		if partType != "text/html" {
+			continue
+		}
+		body, err := decodeTransferEncoding(part, part.Header.Get("Content-Transfer-Encoding"))
+		if err != nil {
+			return nil, fmt.Errorf("MHTML part %d: %w", i, err)
+		}
+		if bytes.Contains(body, []byte("sw-datatable")) {
+			p.log.Tracef("MHTML: using part %d (%s) for the statement", i, part.Header.Get("Content-Location"))
+			return body, nil
+		}
+		p.log.Tracef("MHTML: skipping part %d (%s): no statement tables", i, part.Header.Get("Content-Location"))
+		if first == nil {
+			first = body
+		}
+	}
+	if first == nil {
+		return nil, fmt.Errorf("no html in this MHTML file")
+	}
+	return first, nil
+}
+
+func decodeTransferEncoding(r io.Reader, encoding string) ([]byte, error) {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "quoted-printable":
+		r = quotedprintable.NewReader(r)
+	case "base64":
+		r = base64.NewDecoder(base64.StdEncoding, r) // (It skips over line breaks by itself.)
+	case "", "7bit", "8bit", "binary":
+		// Nothing to do.
+	default:
+		return nil, fmt.Errorf("unsupported transfer encoding %q", encoding)
+	}
+	return ioutil.ReadAll(r)
+}
@@ -0,0 +1,194 @@
+package shareworks
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Money values in the statements come in a frankly impressive variety of shapes:
+// "$42.50 USD", "USD 42.50 per share", "1,234.56*", "$9.00 (1)", and so on.
+// These helpers try to cope with all of them.
+
+// unitSuffixes are the trailing bits that say what the price is per.  We don't need them.
+var unitSuffixes = []string{
+	" per share",
+	" per unit",
+	"/share",
+	"/unit",
+}
+
+// footnoteMarkers are characters that get stuck onto values to point at footnotes.
+const footnoteMarkers = "*†‡§¹²³⁴⁵⁶⁷⁸⁹⁰"
+
+// StripMoneyDecorations removes footnote markers and unit suffixes from a money value,
+// leaving the amount and any currency indicators as they were.
+func StripMoneyDecorations(s string) string {
+	s = strings.TrimSpace(strings.ReplaceAll(s, "\u00a0", " "))
+	for {
+		before := s
+		s = strings.TrimRight(s, footnoteMarkers)
+		s = strings.TrimSpace(s)
+		// Footnote references in brackets, like "(1)" or "[a]", at the end.
+		//  (But not a whole value in parens, which is how negative amounts are written!)
+		for _, pair := range []string{"()", "[]"} {
+			if strings.HasSuffix(s, pair[1:]) {
+				if open := strings.LastIndex(s, pair[:1]); open > 0 && open >= len(s)-4 {
+					s = strings.TrimSpace(s[:open])
+				}
+			}
+		}
+		for _, suffix := range unitSuffixes {
+			if strings.HasSuffix(strings.ToLower(s), suffix) {
+				s = strings.TrimSpace(s[:len(s)-len(suffix)])
+			}
+		}
+		if s == before {
+			return s
+		}
+	}
+}
+
+// currencyIndicators maps the ways currencies show up in values to their codes.
+// Longest first, so "US$" gets a chance before "$" does.
+var currencyIndicators = []struct {
+	indicator string
+	code      string
+}{
+	{"USD", "USD"},
+	{"CAD", "CAD"},
+	{"EUR", "EUR"},
+	{"GBP", "GBP"},
+	{"US$", "USD"},
+	{"CA$", "CAD"},
+	{"C$", "CAD"},
+	{"€", "EUR"},
+	{"£", "GBP"},
+	{"$", ""}, // Ambiguous!  Could be anybody's dollars.
+}
+
+// ParseMoney pulls the number (and the currency code, if there's an unambiguous one) out of a money value,
+// written with a decimal point (as in "1,234.56").
+// Negative amounts can be written with a minus sign or in parentheses.
+func ParseMoney(s string) (amount float64, currency string, err error) {
+	return ParseMoneyIn(s, DecimalPoint)
+}
+
+// ParseEntryMoney is ParseMoney, for a value from ent: it goes by however ent's statement wrote its numbers.
+func ParseEntryMoney(ent Entry, s string) (amount float64, currency string, err error) {
+	return ParseMoneyIn(s, NumberFormatOf(ent))
+}
+
+// ParseMoneyIn is ParseMoney, for a value written in the given number format.
+func ParseMoneyIn(s string, format NumberFormat) (amount float64, currency string, err error) {
+	text := StripMoneyDecorations(s)
+	negative := false
+	if strings.HasPrefix(text, "(") && strings.HasSuffix(text, ")") {
+		negative = true
+		text = text[1 : len(text)-1]
+	}
+	for _, ci := range currencyIndicators {
+		if strings.Contains(text, ci.indicator) {
+			if currency == "" {
+				currency = ci.code
+			}
+			text = strings.ReplaceAll(text, ci.indicator, "")
+		}
+	}
+	switch format {
+	case DecimalComma:
+		// Thousands can be separated with dots, or (in French, say) spaces of one kind or another.
+		text = strings.NewReplacer(".", "", " ", "", "\u00a0", "", "\u202f", "", ",", ".").Replace(text)
+	default:
+		text = strings.ReplaceAll(text, ",", "")
+		text = strings.ReplaceAll(text, " ", "")
+	}
+	if strings.HasPrefix(text, "-") {
+		negative = !negative
+		text = text[1:]
+	}
+	amount, err = strconv.ParseFloat(text, 64)
+	if err != nil {
+		return 0, "", fmt.Errorf("%q doesn't look like a money value", s)
+	}
+	if negative {
+		amount = -amount
+	}
+	return amount, currency, nil
+}
+
+// LooksLikeMoney reports whether a value looks like an amount of money (rather than, say, a share count or a date).
+// It's a guess: a currency sign, or a number with exactly two decimal places (after either a decimal point or a decimal comma).
+func LooksLikeMoney(s string) bool {
+	return strings.ContainsAny(s, "$€£") || fallbackMoneyPattern.MatchString(s) || commaDecimalPattern.MatchString(s)
+}
+
+// moneyNumberPatterns find the number in a money value, with the decimal places as the second group.
+var moneyNumberPatterns = map[NumberFormat]*regexp.Regexp{
+	DecimalPoint: regexp.MustCompile(`\d(?:,?\d)*(\.(\d+))?`),
+	DecimalComma: regexp.MustCompile(`\d(?:[. \x{a0}\x{202f}]?\d)*(,(\d+))?`),
+}
+
+// SumMoneyText adds two money values, and writes the sum in the same style as the first one
+// (same currency markers, same number of decimal places, thousands separators if it had any).
+// It's an error if either isn't a money value, or if they're in different currencies.
+func SumMoneyText(a, b string) (string, error) {
+	return sumMoneyText(a, b, DecimalPoint)
+}
+
+func sumMoneyText(a, b string, format NumberFormat) (string, error) {
+	amountA, currencyA, err := ParseMoneyIn(a, format)
+	if err != nil {
+		return "", err
+	}
+	amountB, currencyB, err := ParseMoneyIn(b, format)
+	if err != nil {
+		return "", err
+	}
+	if currencyA != currencyB {
+		return "", fmt.Errorf("can't add %q and %q: different currencies", a, b)
+	}
+	loc := moneyNumberPatterns[format].FindStringSubmatchIndex(a)
+	if loc == nil || strings.ContainsAny(a, "(-") {
+		// Negative amounts are rare enough here that we won't try to preserve their style.
+		return strconv.FormatFloat(amountA+amountB, 'f', -1, 64), nil
+	}
+	decimals := 0
+	if loc[4] >= 0 {
+		decimals = loc[5] - loc[4]
+	}
+	number := strconv.FormatFloat(amountA+amountB, 'f', decimals, 64)
+	// Put the separators back the way the first value had them.
+	original := a[loc[0]:loc[1]]
+	switch format {
+	case DecimalComma:
+		number = strings.Replace(number, ".", ",", 1)
+		if i := strings.IndexAny(original, ". \u00a0\u202f"); i >= 0 {
+			sep, _ := utf8.DecodeRuneInString(original[i:])
+			number = withThousandsSeparators(number, ',', sep)
+		}
+	default:
+		if strings.Contains(original, ",") {
+			number = withThousandsSeparators(number, '.', ',')
+		}
+	}
+	return a[:loc[0]] + number + a[loc[1]:], nil
+}
+
+// withThousandsSeparators puts sep between every three digits of the whole part of a number.
+func withThousandsSeparators(number string, decimal rune, sep rune) string {
+	whole, fraction := number, ""
+	if i := strings.IndexRune(number, decimal); i >= 0 {
+		whole, fraction = number[:i], number[i:]
+	}
+	var sb strings.Builder
+	for i, r := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			sb.WriteRune(sep)
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String() + fraction
+}
@@ -0,0 +1,65 @@
+package shareworks
+
+import (
+	"testing"
+)
+
+func TestStripMoneyDecorations(t *testing.T) {
+	for _, tc := range []struct {
+		in, want string
+	}{
+		{"USD 42.50 per share", "USD 42.50"},
+		{"$9.00 (1)", "$9.00"},
+		{"1,234.56*", "1,234.56"},
+		{"$42.50†", "$42.50"},
+		{"$42.50 [a]", "$42.50"},
+		{"42.50/unit", "42.50"},
+		{"(42.50)", "(42.50)"},
+		{"US$1,000.00", "US$1,000.00"},
+		{"C$ 5.00", "C$ 5.00"},
+		{" $1.00 ", "$1.00"},
+	} {
+		if got := StripMoneyDecorations(tc.in); got != tc.want {
+			t.Errorf("StripMoneyDecorations(%q) = %q, want %q", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestParseMoneyIn(t *testing.T) {
+	for _, tc := range []struct {
+		in       string
+		format   NumberFormat
+		amount   float64
+		currency string
+	}{
+		{"USD 42.50 per share", DecimalPoint, 42.50, "USD"},
+		{"$9.00 (1)", DecimalPoint, 9, ""},
+		{"1,234.56*", DecimalPoint, 1234.56, ""},
+		{"(42.50)", DecimalPoint, -42.50, ""},
+		{"-42.50", DecimalPoint, -42.50, ""},
+		{"US$1,000.00", DecimalPoint, 1000, "USD"},
+		{"C$ 5.00", DecimalPoint, 5, "CAD"},
+		{"$1,234.56 USD", DecimalPoint, 1234.56, "USD"},
+		{"1.234,56", DecimalComma, 1234.56, ""},
+		{"1 234,56", DecimalComma, 1234.56, ""},
+		{"1 234,56 €", DecimalComma, 1234.56, "EUR"},
+		{"(1.234,56)", DecimalComma, -1234.56, ""},
+	} {
+		amount, currency, err := ParseMoneyIn(tc.in, tc.format)
+		if err != nil {
+			t.Errorf("ParseMoneyIn(%q, %s): %v", tc.in, tc.format, err)
+			continue
+		}
+		if amount != tc.amount || currency != tc.currency {
+			t.Errorf("ParseMoneyIn(%q, %s) = %v %q, want %v %q", tc.in, tc.format, amount, currency, tc.amount, tc.currency)
+		}
+	}
+}
+
+func TestParseMoneyInRejects(t *testing.T) {
+	for _, in := range []string{"", "n/a", "15-Mar-2023", "$"} {
+		if amount, _, err := ParseMoneyIn(in, DecimalPoint); err == nil {
+			t.Errorf("ParseMoneyIn(%q) = %v, want an error", in, amount)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package shareworks
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Statements in other languages write their numbers the other way round: "1.234,56" instead of "1,234.56".
+// Read one of those as if it were "1,234.56", and every amount comes out off by a factor of a hundred or a thousand.
+//
+// Which way round a statement writes them is decided once, for the whole statement, since a single value like "1.234" can't say by itself.
+// Amounts with two decimal places are unambiguous, so those decide it; statements nearly always have some.
+// If there aren't any, the language the page says it's in gets the last word.
+// Values are never rewritten: they come out as the statement had them, and anything that reads them as numbers goes by the entry's format.
+
+// NumberFormat is which way round a statement writes its decimal and thousands separators.
+type NumberFormat string
+
+const (
+	DecimalPoint NumberFormat = "point" // "1,234.56".
+	DecimalComma NumberFormat = "comma" // "1.234,56", or "1 234,56".
+)
+
+// NumberFormatKey is where an entry from a statement with decimal commas says so.
+// Entries without it use decimal points.  Like RawHTMLKey, it's never in the columns.
+const NumberFormatKey = "Number Format"
+
+// NumberFormatOf returns the number format of an entry.
+func NumberFormatOf(ent Entry) NumberFormat {
+	if ent[NumberFormatKey] == string(DecimalComma) {
+		return DecimalComma
+	}
+	return DecimalPoint
+}
+
+// ParseNumberFormat parses "point" or "comma".
+func ParseNumberFormat(s string) (NumberFormat, bool) {
+	switch f := NumberFormat(strings.ToLower(strings.TrimSpace(s))); f {
+	case DecimalPoint, DecimalComma:
+		return f, true
+	}
+	return "", false
+}
+
+var (
+	// Two decimal places after the separator, and then no more digits.
+	pointDecimalPattern = regexp.MustCompile(`\d\.\d{2}(\D|$)`)
+	commaDecimalPattern = regexp.MustCompile(`\d,\d{2}(\D|$)`)
+)
+
+// decimalCommaLanguages are the languages (as in the html lang attribute) that write decimal commas.
+var decimalCommaLanguages = map[string]bool{
+	"de": true, "fr": true, "es": true, "it": true, "nl": true, "pt": true,
+	"da": true, "sv": true, "nb": true, "no": true, "fi": true, "pl": true, "cs": true,
+}
+
+// DetectNumberFormat works out which number format some values are in.
+// lang is the language the document says it's in (like "de-DE"), or "" if it doesn't say.
+func DetectNumberFormat(lang string, values []string) NumberFormat {
+	points, commas := 0, 0
+	for _, v := range values {
+		if pointDecimalPattern.MatchString(v) {
+			points++
+		}
+		if commaDecimalPattern.MatchString(v) {
+			commas++
+		}
+	}
+	switch {
+	case commas > points:
+		return DecimalComma
+	case points > commas:
+		return DecimalPoint
+	}
+	lang = strings.ToLower(lang)
+	if i := strings.IndexAny(lang, "-_"); i >= 0 {
+		lang = lang[:i]
+	}
+	if decimalCommaLanguages[lang] {
+		return DecimalComma
+	}
+	return DecimalPoint
+}
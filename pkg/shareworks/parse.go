@@ -0,0 +1,583 @@
+package shareworks
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// parse does the actual work for Parse.
+func (p *parser) parse(bs []byte) (columns []string, entries []Entry, err error) {
+	filename := p.filename
+	if IsMhtmlFilename(filename) || (!strings.HasSuffix(strings.ToLower(filename), ".html") && looksLikeMhtml(bs)) {
+		bs, err = p.htmlFromMhtml(bs)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to open %s: %w", p.name(), err)
+		}
+	}
+	// Make sure we got all of it.
+	truncation, truncated := detectTruncation(bs)
+	if truncated {
+		if !p.salvage {
+			return nil, nil, fmt.Errorf("%s looks truncated: %s (line %d, byte %d) -- try saving it again, or use --salvage to get what's there", p.name(), truncation.reason, truncation.line, truncation.offset)
+		}
+		p.log.Warnf(WarnTruncated, "%s looks truncated: %s (line %d, byte %d).  Salvaging what's there; the last event may be incomplete.", p.name(), truncation.reason, truncation.line, truncation.offset)
+	}
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(bs))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open %s as html: %w", p.name(), err)
+	}
+
+	// Check for the most likely data collection error: the enclosing page, rather than what's in the iframe.
+	//  If what was in the iframe got saved too, we can use that; otherwise, say so specifically.
+	doc, err = p.innerStatementDocument(doc)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Work out which way round the numbers are written, unless we've been told.
+	if p.numberFormat == "" {
+		var values []string
+		doc.Find("td").Each(func(_ int, td *goquery.Selection) {
+			values = append(values, td.Text())
+		})
+		p.numberFormat = DetectNumberFormat(doc.Find("html").AttrOr("lang", ""), values)
+		p.log.Tracef("%s: numbers are written with a decimal %s", p.name(), p.numberFormat)
+	}
+
+	// All the relevant data is in tables with this class.
+	//  A lot of irrelevant data is too, but we'll sort that out later.
+	tablesSelection := doc.Find("table.sw-datatable")
+	if tablesSelection.Length() < 1 {
+		return p.mungeFallback(doc, fmt.Errorf("found no shareworks data tables -- are you sure this is the right html?"))
+	}
+
+	// Pluck out tables that have a header row that contains the text "Release".
+	//  The "Release" tables are the only ones that are useful.
+	//  (Other tables contain summaries, but the summaries are... basically useless, and exclude all of the facts that are actually relevant.  Amazing.)
+	tablesSelection = tablesSelection.FilterFunction(func(i int, sel *goquery.Selection) bool {
+		headerText := sel.Find("th.newReportTitleStyle").First().Text()
+		return strings.Contains(headerText, "Release") || isDividendTitle(headerText) || p.tableHandler(strings.TrimSpace(headerText)) != nil
+	})
+	if tablesSelection.Length() < 1 {
+		return p.mungeFallback(doc, fmt.Errorf("none of the shareworks data tables had titles containing the word 'Release' -- are you sure this is the right html?  We expected the events to all have 'Release' in the title somewhere."))
+	}
+
+	// BUT WAIT!  THERE'S MORE!
+	// Look for h2 tags.  These contain the info about which kind of good we're handling.
+	//  This is super important if you have more than one kind of stock or token being reported.
+	//  Note that this information is NOT the actual stock or good itself -- it's the distribution schedule name.
+	//   You'll have to demux that information back onto the actual stock or good manually with information in your hands as a human -- the document **literally** does not contain this information, as far as I can tell.
+	// We have to do this in *the same query* as getting the tables, so that they're interleaved in the correct order in our selection here --
+	//  the h2 tags aren't parents of the data they describe, they're just *before* the data they describe.  Additional "whee" for parsing :))))
+	//   Can you imagine how great it would be if these tables actually say which unit they're denominated in?  But they don't :D :D :D :D
+	//  So, that tablesSelection var earlier is demoted to just being another sanitychecker, and we'll loop over this below, looking for both tables and h2 tags.
+	//   And we'll be re-doing the filter for tables-that-are-actually-relevant below, too.  Agghsdfhwefhsdfh.
+	tablesAndHeadersSelection := doc.Find("h2, table.sw-datatable")
+
+	// Okay, it's almost time to start accumulating data.
+	// I'm gonna kinda try to normalize this to columnar as we go;
+	//  and I'm not hard-coding any column headings,
+	//   so, first encounter with a data entry in the whole document determins the order in which it will appear as a column.
+	// See the definition of `columns` and `entries` at the top, in the function's returns.
+
+	// We also need one slot of memory to remember the text of the last h2 tag we saw,
+	//  because that's the distribution schedule name, and will apply to several rows, which we're about to loop over.
+	var distributionScheduleName string
+	// If the statement got split across several files, the heading for the first few tables here might've been at the end of the previous file.
+	if p.scheduleContext != nil {
+		distributionScheduleName = *p.scheduleContext
+		defer func() { *p.scheduleContext = distributionScheduleName }()
+	}
+
+	// Go over the whole melange.
+	// The headers become one column; the tables that are relevant each become one row in our sanitized data.
+	// Yeah, one table becomes one row.  Yeah.  Yeahhhhh.
+	// This is why your accountant didn't want to work with this format.  Because it's insane.  This is not how data should be formatted.
+	// Anyway, let's go:
+	tablesAndHeadersSelection.Each(func(i int, sel *goquery.Selection) {
+		// First: see if this is:
+		//  - a heading (e.g. might indicate which distribution schedule the following tables are for),
+		//  - or if it's a table that we care about (e.g. it describes a distribution event),
+		//  - or if it's one of the other tables that's useless (see earlier comments).
+		// If it's a heading, we'll handle that in this logic block;
+		// if it's a useless table, we'll skip out;
+		// if it's a relevant table, the majority of the logic will continue below.
+		// (Or, if it's a table one of the WithTableHandler handlers wants, the handler does the rest.)
+		var handler *TableHandler
+		switch {
+		case sel.Is("h2"):
+			distributionScheduleName = strings.TrimPrefix(strings.TrimSpace(sel.Text()), "Summary of ")
+			p.log.Tracef("%s: element %d: heading: distribution schedule is now %q", p.name(), i, distributionScheduleName)
+			return
+		case sel.Is("table.sw-datatable"):
+			headerText := sel.Find("th.newReportTitleStyle").First().Text()
+			isRelease := strings.Contains(headerText, "Release")
+			isWithdrawal := strings.Contains(headerText, "Withdrawal on")
+			isDividend := isDividendTitle(headerText)
+			if !isRelease && !isWithdrawal && !isDividend {
+				handler = p.tableHandler(strings.TrimSpace(headerText))
+			}
+			if !isRelease && !isWithdrawal && !isDividend && handler == nil {
+				p.log.Tracef("%s: element %d: skipping table %q", p.name(), i, strings.TrimSpace(headerText))
+				return
+			}
+			p.log.Tracef("%s: element %d: parsing table %q", p.name(), i, strings.TrimSpace(headerText))
+			defer p.timeTable(strings.TrimSpace(headerText), time.Now())
+			// if it contains either word, it's relevant: continue...
+		default:
+			panic("unreachable, earlier filter should not have matched this")
+		}
+
+		// Make some temporary memory to put this row's data in as we find it.
+		row := Entry{}
+		entries = append(entries, row)
+		// Keep track of which tables this row came from, in case we're asked to include the raw html.
+		sourceTables := []*goquery.Selection{sel}
+
+		// Append the distributionScheduleName as a column.
+		p.accumulate(&columns, row, "Distribution Schedule", distributionScheduleName)
+		// When the schedule is meant to carry over from an earlier file, not finding one means something's missing.
+		if distributionScheduleName == "" && p.scheduleContext != nil {
+			Doubt(&columns, row, ConfidenceMedium, "no distribution schedule heading before this table, or in the files before this one")
+		}
+
+		// Pick a title for the event.
+		//  We'll use that same table header that we happened to already look at above to filter the tables in the first place.
+		headerText := strings.TrimSpace(sel.Find("th.newReportTitleStyle").First().Text())
+		p.accumulate(&columns, row, "Event", headerText)
+		p.debugEvent(headerText, distributionScheduleName)
+		p.debugTable(headerText)
+		// The title is something like "Release on 15-Mar-2023 of 2021 RSU Grant", which is a lot of stuff in one cell.
+		//  Split the date out from the rest of it, for anyone who wants them separately.
+		if date, description, ok := splitEventTitle(headerText); ok {
+			p.accumulate(&columns, row, "Event Date", date)
+			p.accumulate(&columns, row, "Event Description", description)
+		}
+
+		// Add the Type column
+		if strings.Contains(headerText, "Release") {
+			p.accumulate(&columns, row, "Type", "Buy")
+		} else if strings.Contains(headerText, "Withdrawal on") {
+			p.accumulate(&columns, row, "Type", "Sell")
+		} else if isDividendTitle(headerText) {
+			p.accumulate(&columns, row, "Type", "Dividend")
+		}
+		p.accumulate(&columns, row, "Confidence", ConfidenceHigh)
+
+		// A handler's table is its business from here on.
+		if handler != nil {
+			handler.Extract(sel, func(label, value string) {
+				p.debugPair(label, value)
+				p.accumulate(&columns, row, label, value)
+			})
+			if _, ok := row["Settlement Date:"]; !ok {
+				Doubt(&columns, row, ConfidenceMedium, "no settlement date")
+			}
+			if p.rawHTML {
+				row[RawHTMLKey] = sanitizedHtml(sourceTables)
+			}
+			return
+		}
+
+		// Some brain genius made a four-column layout: two columns of two paired columns.  KVKV.
+		// So we get to suss that back out.  Neato.
+		// They tend to read top-bottom and then top-bottom again, and I'm actually going to bother to parse that ordering.
+		var col1, col2, col3, col4 []string
+		sel.Find("tr").Each(func(i int, sel *goquery.Selection) {
+			sel.Find("td.staticViewTableColumn1").Each(func(i int, sel *goquery.Selection) {
+				if i%2 == 0 {
+					col1 = append(col1, strings.TrimSpace(sel.Text()))
+				} else {
+					col3 = append(col3, strings.TrimSpace(sel.Text()))
+				}
+			})
+			sel.Find("td.staticViewTableColumn2").Each(func(i int, sel *goquery.Selection) {
+				if i%2 == 0 {
+					col2 = append(col2, strings.TrimSpace(sel.Text()))
+				} else {
+					col4 = append(col4, strings.TrimSpace(sel.Text()))
+				}
+			})
+		})
+		if len(col1) != len(col2) || len(col3) != len(col4) {
+			Doubt(&columns, row, ConfidenceMedium, "label and value cells didn't pair up evenly")
+		}
+		for i := range col1 {
+			if i < len(col2) {
+				p.debugPair(col1[i], col2[i])
+				p.accumulate(&columns, row, col1[i], col2[i])
+			}
+		}
+		for i := range col3 {
+			if i < len(col4) {
+				p.debugPair(col3[i], col4[i])
+				p.accumulate(&columns, row, col3[i], col4[i])
+			}
+		}
+		if _, ok := row["Settlement Date:"]; !ok {
+			Doubt(&columns, row, ConfidenceMedium, "no settlement date")
+		}
+
+		// Process additional tables that follow the main table
+		if strings.Contains(headerText, "Release") {
+			// For releases, find and process the "Value of Shares Sold" tables that follow.
+			//  Usually there's just one, but a release that got sold in several batches has one of these (and a total) per batch.
+			//  We add the batches up, so that nothing gets dropped.
+			batches := 0
+			batched := map[string]bool{}
+			nextTable := sel.Next()
+			for nextTable.Length() > 0 && nextTable.Is("table.sw-datatable") {
+				// Check if it's a "Value of Shares Sold" table
+				headerText := nextTable.Find("th.newReportHeadingStyle").First().Text()
+				if strings.TrimSpace(headerText) != "Value of Shares Sold" {
+					break
+				}
+				batches++
+				var batchColumns []string
+				batchRow := Entry{"Type": row["Type"]}
+				p.processValueTable(nextTable, "Value of Shares Sold", p.breakdownPrefixes, &batchColumns, batchRow)
+				sourceTables = append(sourceTables, nextTable)
+
+				// Get the total value from the next table
+				totalTable := nextTable.Next()
+				nextTable = totalTable
+				if totalTable.Length() > 0 && totalTable.Is("table.sw-datatable") {
+					if label, totalValue, ok := findTotal(totalTable, p.totalLabels); ok {
+						p.debugTable("")
+						p.debugPair(label, totalValue)
+						p.accumulate(&batchColumns, batchRow, p.breakdownPrefixes.Total("Value of Shares Sold", "Total Value"), totalValue)
+						sourceTables = append(sourceTables, totalTable)
+						nextTable = totalTable.Next()
+					}
+				}
+				p.addBatch(&columns, row, batchColumns, batchRow, batches, batched)
+			}
+			if batches > 1 {
+				p.accumulate(&columns, row, "Sale Batches", strconv.Itoa(batches))
+			}
+		} else if strings.Contains(headerText, "Withdrawal on") || isDividendTitle(headerText) {
+			// For withdrawals (and dividends), process all the following tables until we hit a non-relevant one
+			currentTable := sel.Next()
+			for currentTable.Length() > 0 {
+				if !currentTable.Is("table.sw-datatable") {
+					break
+				}
+
+				headerText := currentTable.Find("th.newReportHeadingStyle, th.newReportTitleStyle").First().Text()
+				if headerText == "" {
+					currentTable = currentTable.Next()
+					continue
+				}
+				headerText = strings.TrimSpace(headerText)
+
+				// Process tables based on their headers
+				switch headerText {
+				case "Sale Breakdown", "Electronic Share Transfer", "Mail cash to broker", "Net Proceeds", "Dividend Breakdown":
+					p.processValueTable(currentTable, headerText, p.breakdownPrefixes, &columns, row)
+					sourceTables = append(sourceTables, currentTable)
+
+					// Check for total value table
+					totalTable := currentTable.Next()
+					if totalTable.Length() > 0 && totalTable.Is("table.sw-datatable") {
+						if label, totalValue, ok := findTotal(totalTable, p.totalLabels); ok {
+							p.debugTable("")
+							p.debugPair(label, totalValue)
+							p.accumulate(&columns, row, p.breakdownPrefixes.Total(headerText, headerText+" Total"), totalValue)
+							sourceTables = append(sourceTables, totalTable)
+							currentTable = totalTable.Next()
+							continue
+						}
+					}
+				}
+				currentTable = currentTable.Next()
+			}
+		}
+
+		if p.rawHTML {
+			row[RawHTMLKey] = sanitizedHtml(sourceTables)
+		}
+	})
+
+	// If the file was cut off, the last event in it (in document order, so before sorting) is the one that might be missing things.
+	if truncated && len(entries) > 0 {
+		Doubt(&columns, entries[len(entries)-1], ConfidenceLow, fmt.Sprintf("the file looks truncated at line %d; this event may be incomplete", truncation.line))
+	}
+
+	if !p.documentOrder {
+		SortEntries(entries, p.dateLayouts, p.log)
+	}
+
+	return columns, entries, nil
+}
+
+// isDividendTitle reports whether an event title is for a dividend, or a dividend equivalent (which is what RSUs get instead),
+// like "Dividend on 15-Jun-2023" or "Dividend Equivalent on 15-Jun-2023".
+// Those titles (and the "Dividend Breakdown" table that follows) are what we expect, not what we've seen: there's no real statement with dividends to check them against yet.
+func isDividendTitle(title string) bool {
+	title = strings.TrimSpace(title)
+	return strings.HasPrefix(title, "Dividend on ") || strings.HasPrefix(title, "Dividend Equivalent on ")
+}
+
+// mungeFallback tries the positional parser, when the regular one couldn't find what it was looking for.
+// If that finds nothing either, the original complaint is returned.
+func (p *parser) mungeFallback(doc *goquery.Document, complaint error) (columns []string, entries []Entry, err error) {
+	columns, entries = p.mungePositional(doc)
+	if len(entries) == 0 {
+		return nil, nil, complaint
+	}
+	p.log.Warnf(WarnFallbackParser, "%s: %s", p.name(), complaint)
+	p.log.Warnf(WarnFallbackParser, "%s: falling back to dumping every table that looks like it has dates and money in it.  The %d rows from this are in \"Unknown\" columns and will need checking by hand.", p.name(), len(entries))
+	return columns, entries, nil
+}
+
+var eventTitlePattern = regexp.MustCompile(`^(.*?)\s+on\s+(\d{1,2}-[A-Za-z]{3}-\d{4})\b\s*(.*)$`)
+
+// splitEventTitle splits an event title like "Release on 15-Mar-2023 of 2021 RSU Grant"
+// into the date ("15-Mar-2023") and the rest ("Release of 2021 RSU Grant").
+func splitEventTitle(title string) (date string, description string, ok bool) {
+	m := eventTitlePattern.FindStringSubmatch(title)
+	if m == nil {
+		return "", "", false
+	}
+	return m[2], strings.TrimSpace(m[1] + " " + m[3]), true
+}
+
+// SortEntries sorts entries by Settlement Date.
+// The sort is stable, so events on the same date stay in the order they came in (document order, then extra events).
+// Entries without a usable settlement date stay put relative to their neighbors:
+// they're sorted as if they had the same date as whatever came just before them.
+// Dates are read with layouts, or DefaultDateLayouts if that's nil.
+// Settlement dates that can't be parsed are warned about to log, if it isn't nil.
+func SortEntries(entries []Entry, layouts DateLayouts, log Logger) {
+	if layouts == nil {
+		layouts = DefaultDateLayouts
+	}
+	if log == nil {
+		log = nopLogger{}
+	}
+	dates := make([]time.Time, len(entries))
+	var previous time.Time
+	for i, ent := range entries {
+		dates[i] = previous
+		text, ok := ent["Settlement Date:"]
+		if !ok {
+			continue
+		}
+		t, err := layouts.Parse(text)
+		if err != nil {
+			log.Warnf(WarnUnparseableDate, "Could not parse date %q: %v", text, err)
+			continue
+		}
+		dates[i] = t
+		previous = t
+	}
+	// Sort a permutation rather than the entries directly, so the dates can come along for the ride.
+	order := make([]int, len(entries))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return dates[order[i]].Before(dates[order[j]])
+	})
+	sorted := make([]Entry, len(entries))
+	for i, idx := range order {
+		sorted[i] = entries[idx]
+	}
+	copy(entries, sorted)
+}
+
+// Helper function to process value tables (used for both Release and Withdrawal tables)
+func (p *parser) processValueTable(table *goquery.Selection, tableName string, prefixes BreakdownPrefixes, columns *[]string, row Entry) {
+	p.debugTable(tableName)
+	table.Find("tr").Each(func(i int, tr *goquery.Selection) {
+		// Skip the header row
+		if i == 0 {
+			return
+		}
+
+		// Get the key and value from the cells
+		var key, value string
+		tr.Find("td.newReportCellStyle").Each(func(j int, td *goquery.Selection) {
+			text := strings.TrimSpace(td.Text())
+			if j == 0 {
+				key = text
+			} else if j == 1 {
+				value = text
+			}
+		})
+		if key != "" && value != "" {
+			p.debugPair(key, value)
+			p.accumulate(columns, row, prefixes.Column(tableName, key, row["Type"]), value)
+		}
+	})
+}
+
+// DefaultTotalLabels are the labels that total rows are known to use.
+// Order matters: longer labels that start the same as shorter ones have to come first.
+var DefaultTotalLabels = []string{"Total Value:", "Net Proceeds Total:", "Total:"}
+
+// findTotal looks for a "Total Value: $1,234.56"-style cell in a table, and returns the label it matched and the value part.
+// The label can be any of the given labels.
+//
+// This is fussier than it sounds.  Sometimes the cell starts with a few non-breaking spaces.
+// Sometimes the label is split across several spans, so the text comes out as "TotalValue:" or "Total\n   Value:".
+// Sometimes the value is in the next cell over.
+// So: we match the label ignoring all whitespace, and if there's nothing after the label in the cell, we look at the rest of the row.
+func findTotal(table *goquery.Selection, labels []string) (label string, value string, ok bool) {
+	cell := table.Find("td.defaultTableModelTextBold").First()
+	if cell.Length() == 0 {
+		return "", "", false
+	}
+	for _, label := range labels {
+		value, ok := cutLabel(cell.Text(), label)
+		if !ok {
+			continue
+		}
+		if value == "" {
+			value = normalizeSpace(cell.NextAll().Text())
+		}
+		return label, value, true
+	}
+	return "", "", false
+}
+
+// normalizeSpace turns all runs of whitespace (including non-breaking spaces) into single spaces, and trims the ends.
+func normalizeSpace(s string) string {
+	return strings.Join(strings.Fields(strings.ReplaceAll(s, "\u00a0", " ")), " ")
+}
+
+// cutLabel checks if text starts with label -- ignoring any whitespace in either -- and returns the rest of the text, tidied up.
+func cutLabel(text string, label string) (rest string, ok bool) {
+	want := []rune(strings.Join(strings.Fields(label), ""))
+	runes := []rune(text)
+	i := 0
+	for _, w := range want {
+		for i < len(runes) && (unicode.IsSpace(runes[i]) || runes[i] == '\u00a0') {
+			i++
+		}
+		if i >= len(runes) || unicode.ToLower(runes[i]) != unicode.ToLower(w) {
+			return "", false
+		}
+		i++
+	}
+	return normalizeSpace(string(runes[i:])), true
+}
+
+// addBatch folds the values from one batch of a multi-batch sale into the row.
+// Values for labels no earlier batch had are just added;
+// values for labels an earlier batch already had are summed with what's there, if they're amounts that add up (see isAdditiveLabel).
+// Anything else -- prices per share, values that aren't numbers, values in different currencies -- gets its own numbered column for the later batch.
+// batched remembers which labels the earlier batches had.
+func (p *parser) addBatch(columns *[]string, row Entry, batchColumns []string, batchRow Entry, batch int, batched map[string]bool) {
+	for _, key := range batchColumns {
+		value := batchRow[key]
+		if !batched[key] {
+			batched[key] = true
+			p.accumulate(columns, row, key, value)
+			continue
+		}
+		if isAdditiveLabel(key) {
+			if sum, err := sumMoneyText(row[key], value, p.numberFormat); err == nil {
+				row[key] = sum
+				continue
+			}
+		}
+		p.accumulate(columns, row, fmt.Sprintf("%s (batch %d)", key, batch), value)
+	}
+}
+
+// isAdditiveLabel reports whether the values under a label add up across the batches of a sale:
+// share counts, proceeds, fees, and totals do; prices per share don't.
+func isAdditiveLabel(label string) bool {
+	label = strings.ToLower(label)
+	for _, word := range []string{"price", "per unit", "per share", "rate", "fair market value"} {
+		if strings.Contains(label, word) {
+			return false
+		}
+	}
+	for _, word := range []string{"shares", "stocks report", "proceeds", "fee", "commission", "total", "value", "amount", "tax"} {
+		if strings.Contains(label, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// NormalizeColumnName is what a label becomes as a column name.
+// The labels for share counts and prices differ between kinds of event; they all become "stocks report" and "price per unit".
+// Everything else keeps its label.
+func NormalizeColumnName(originalName, eventType string) string {
+	switch {
+	case eventType == "Buy" && originalName == "Number of Restricted Awards Disbursed:":
+		return "stocks report"
+	case eventType == "Sell" && originalName == "Shares Sold:":
+		return "stocks report"
+	case eventType == "Buy" && originalName == "Release Price:":
+		return "price per unit"
+	case eventType == "Sell" && originalName == "Market Price Per Unit:":
+		return "price per unit"
+	default:
+		return originalName
+	}
+}
+
+// Accumulate sets a value in a row, under the normalized name for its label,
+// and adds that column to the column order if this is the first time it's been seen.
+func Accumulate(columnOrder *[]string, row Entry, key string, value string) {
+	accumulate(columnOrder, row, key, value, nil)
+}
+
+func (p *parser) accumulate(columnOrder *[]string, row Entry, key string, value string) {
+	accumulate(columnOrder, row, key, value, p.onNormalize)
+}
+
+// accumulate is Accumulate, telling onNormalize (if it isn't nil) about any label that got renamed.
+func accumulate(columnOrder *[]string, row Entry, key string, value string, onNormalize func(eventType, original, normalized string)) {
+	// Get the event type from the row
+	eventType := row["Type"]
+
+	// Normalize the column name
+	normalizedKey := NormalizeColumnName(key, eventType)
+
+	// Prices sometimes come with footnote markers or a "per share" tacked on.  Strip those, so the column stays numbers.
+	if normalizedKey == "price per unit" {
+		value = StripMoneyDecorations(value)
+	}
+
+	// If the key was normalized, we need to handle both the normalized and original names
+	if normalizedKey != key {
+		if onNormalize != nil {
+			onNormalize(eventType, key, normalizedKey)
+		}
+		row[normalizedKey] = value
+		// Check if we need to add the normalized column name
+		found := false
+		for _, col := range *columnOrder {
+			if col == normalizedKey {
+				found = true
+				break
+			}
+		}
+		if !found {
+			*columnOrder = append(*columnOrder, normalizedKey)
+		}
+		return
+	}
+
+	// Original accumulate logic for non-normalized keys
+	row[key] = value
+	for _, col := range *columnOrder {
+		if col == key {
+			return
+		}
+	}
+	*columnOrder = append(*columnOrder, key)
+}
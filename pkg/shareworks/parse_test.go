@@ -0,0 +1,129 @@
+package shareworks
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func parseFixture(t *testing.T, filename string, opts ...Option) *Statement {
+	t.Helper()
+	f, err := os.Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	stmt, err := Parse(f, append([]Option{WithFilename(filename)}, opts...)...)
+	if err != nil {
+		t.Fatalf("%s: %v", filename, err)
+	}
+	return stmt
+}
+
+func TestParseMultiBatchRelease(t *testing.T) {
+	stmt := parseFixture(t, "testdata/two-batches.html")
+	if len(stmt.Entries) != 1 {
+		t.Fatalf("got %d entries, want 1", len(stmt.Entries))
+	}
+	ent := stmt.Entries[0]
+	for column, want := range map[string]string{
+		"stocks report":                  "100",
+		"price per unit":                 "$150.00 USD",
+		"Shares Sold to Cover":           "35",
+		"Commission":                     "$2.75 USD",
+		"Total Value":                    "$5,280.00 USD",
+		"Sale Price Per Share":           "$150.00 USD",
+		"Sale Price Per Share (batch 2)": "$152.00 USD",
+		"Sale Batches":                   "2",
+	} {
+		if got := ent[column]; got != want {
+			t.Errorf("%q: got %q, want %q", column, got, want)
+		}
+	}
+}
+
+func TestIsAdditiveLabel(t *testing.T) {
+	for label, want := range map[string]bool{
+		"Shares Sold to Cover": true,
+		"stocks report":        true,
+		"Gross Proceeds":       true,
+		"Commission":           true,
+		"SEC Fee":              true,
+		"Total Value":          true,
+		"price per unit":       false,
+		"Sale Price Per Share": false,
+		"Fair Market Value":    false,
+		"Settlement Date:":     false,
+	} {
+		if got := isAdditiveLabel(label); got != want {
+			t.Errorf("isAdditiveLabel(%q) = %v, want %v", label, got, want)
+		}
+	}
+}
+
+func TestParseSidecarFiles(t *testing.T) {
+	stmt := parseFixture(t, "testdata/enclosing.html", WithSidecarFiles())
+	if len(stmt.Entries) != 1 {
+		t.Fatalf("got %d entries, want 1", len(stmt.Entries))
+	}
+
+	// Without the option, the files next to it are none of Parse's business.
+	f, err := os.Open("testdata/enclosing.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := Parse(f, WithFilename("testdata/enclosing.html")); err == nil || !strings.Contains(err.Error(), "enclosing document") {
+		t.Errorf("got error %v, want one about the enclosing document", err)
+	}
+}
+
+func TestParseTableHandler(t *testing.T) {
+	cashAwards := TableHandler{
+		Match: func(title string) bool { return strings.HasPrefix(title, "Cash Award on ") },
+		Extract: func(table *goquery.Selection, add func(label, value string)) {
+			add("Type", "Cash")
+			table.Find("tr").Each(func(_ int, tr *goquery.Selection) {
+				cells := tr.Find("td.planCell")
+				if cells.Length() == 2 {
+					add(strings.TrimSpace(cells.First().Text()), strings.TrimSpace(cells.Last().Text()))
+				}
+			})
+		},
+	}
+	stmt := parseFixture(t, "testdata/custom-table.html", WithTableHandler(cashAwards))
+	if len(stmt.Entries) != 2 {
+		t.Fatalf("got %d entries, want 2", len(stmt.Entries))
+	}
+	ent := stmt.Entries[1]
+	for column, want := range map[string]string{
+		"Distribution Schedule": "2022 Cash Plan",
+		"Event":                 "Cash Award on 01-Apr-2023 of 2022 Cash Grant",
+		"Event Date":            "01-Apr-2023",
+		"Type":                  "Cash",
+		"Settlement Date:":      "03-Apr-2023",
+		"Amount Paid:":          "$500.00 USD",
+		"Confidence":            ConfidenceHigh,
+	} {
+		if got := ent[column]; got != want {
+			t.Errorf("%q: got %q, want %q", column, got, want)
+		}
+	}
+
+	// Without the handler, it's just another table that isn't an event.
+	stmt = parseFixture(t, "testdata/custom-table.html")
+	if len(stmt.Entries) != 1 {
+		t.Errorf("got %d entries without the handler, want 1", len(stmt.Entries))
+	}
+}
+
+func TestParseReadError(t *testing.T) {
+	_, err := Parse(iotest.ErrReader(errors.New("oops")))
+	if err == nil || err.Error() != "failed to read the statement: oops" {
+		t.Errorf("got error %v", err)
+	}
+}
@@ -0,0 +1,47 @@
+package shareworks
+
+import (
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+)
+
+// RawHTMLKey is where an entry keeps the html of the tables it was parsed from, when WithRawHTML is used (--include-raw-html, on the command line).
+// It's never in the column order, so the csv never sees it; only the json emitter looks for it.
+const RawHTMLKey = "Raw HTML"
+
+// sanitizedHtml returns the outer html of the given tables, with scripts and styles removed,
+// and with only the attributes a parser might reasonably care about (class, id, colspan, rowspan) kept.
+func sanitizedHtml(tables []*goquery.Selection) string {
+	var sb strings.Builder
+	for _, table := range tables {
+		clone := table.Clone()
+		clone.Find("script, style").Remove()
+		for _, n := range clone.Nodes {
+			sanitizeNode(n)
+		}
+		s, err := goquery.OuterHtml(clone)
+		if err != nil {
+			continue
+		}
+		sb.WriteString(s)
+	}
+	return sb.String()
+}
+
+func sanitizeNode(n *html.Node) {
+	if n.Type == html.ElementNode {
+		kept := n.Attr[:0]
+		for _, attr := range n.Attr {
+			switch attr.Key {
+			case "class", "id", "colspan", "rowspan":
+				kept = append(kept, attr)
+			}
+		}
+		n.Attr = kept
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		sanitizeNode(c)
+	}
+}
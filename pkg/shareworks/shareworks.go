@@ -0,0 +1,200 @@
+// Package shareworks parses the html statements that Shareworks produces into plain rows,
+// one per event (a release, a withdrawal, a dividend), with one column per label.
+// (Dividends are provisional: their titles and their "Dividend Breakdown" table haven't been checked against a real statement yet.)
+//
+// This is the parser from the shareworks-munger command, without the command:
+// no csv, no options about output, no opinions about files.
+// Parse reads what it's given and nothing else, unless WithSidecarFiles lets it look next to the statement on disk.
+// If you want to do something with your statements that the command doesn't, start with Parse.
+//
+// Column names are the labels from the statement, as they were written ("Settlement Date:", colon and all),
+// except for the share count and the price, which are normalized to "stocks report" and "price per unit" for every kind of event.
+// A few columns are made up by the parser: "Distribution Schedule", "Event", "Event Date", "Event Description", "Type", "Confidence", and "Confidence Note".
+package shareworks
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"time"
+)
+
+// Entry is one event: column name -> value, both exactly as the statement had them (give or take normalization).
+type Entry = map[string]string
+
+// Statement is what Parse returns.
+// Columns is every column that any entry has, in the order they were first seen;
+// Entries is the events, sorted by settlement date (unless WithDocumentOrder was used).
+// NumberFormat is which way round the statement writes its numbers; the entries say so too, under NumberFormatKey.
+// Debug is only filled in with WithDebug: what the parser saw for each event, in the order they were in the statement.
+type Statement struct {
+	Columns      []string
+	Entries      []Entry
+	NumberFormat NumberFormat
+	Debug        []DebugEvent
+}
+
+// Parse reads a whole statement from r, and pulls the events out of it.
+// It copes with the same things the command does: MHTML saves, the enclosing page (if the statement got saved inside it, or, with WithSidecarFiles, next to it), and so on.
+func Parse(r io.Reader, opts ...Option) (*Statement, error) {
+	p := &parser{config: config{
+		log:         nopLogger{},
+		totalLabels: DefaultTotalLabels,
+	}}
+	for _, opt := range opts {
+		opt(&p.config)
+	}
+	bs, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", p.name(), err)
+	}
+	columns, entries, err := p.parse(bs)
+	if err != nil {
+		return nil, err
+	}
+	if p.numberFormat == DecimalComma {
+		for _, ent := range entries {
+			ent[NumberFormatKey] = string(DecimalComma)
+		}
+	}
+	return &Statement{Columns: columns, Entries: entries, NumberFormat: p.numberFormat, Debug: p.debugEvents}, nil
+}
+
+// Option changes how Parse works.  The zero set of options does what the command does with no flags.
+type Option func(*config)
+
+type config struct {
+	filename          string
+	sidecarFiles      bool
+	log               Logger
+	totalLabels       []string
+	breakdownPrefixes BreakdownPrefixes
+	rawHTML           bool
+	salvage           bool
+	scheduleContext   *string
+	onNormalize       func(eventType, original, normalized string)
+	onTableTiming     func(title string, d time.Duration)
+	documentOrder     bool
+	numberFormat      NumberFormat
+	dateLayouts       DateLayouts
+	debug             bool
+	tableHandlers     []TableHandler
+}
+
+// name is how messages refer to the statement: its filename, if it has one.
+func (c config) name() string {
+	if c.filename == "" {
+		return "the statement"
+	}
+	return strconv.Quote(c.filename)
+}
+
+// parser is one run of Parse.
+type parser struct {
+	config
+	debugEvents []DebugEvent
+}
+
+// WithFilename says where the statement came from.
+// It's used in messages, and to spot MHTML files by their suffix.  Nothing is read from it; that's what r is for.
+func WithFilename(filename string) Option {
+	return func(c *config) { c.filename = filename }
+}
+
+// WithSidecarFiles lets Parse read files next to the statement, as named by WithFilename.
+// If the statement turns out to be the page around the iframe the statement really lives in,
+// "Save Page As... (complete)" may have put the real thing in a "_files" directory beside it, and with this, Parse goes and looks.
+func WithSidecarFiles() Option {
+	return func(c *config) { c.sidecarFiles = true }
+}
+
+// WithLogger sends messages about the parse to log.  By default they're dropped.
+func WithLogger(log Logger) Option {
+	return func(c *config) { c.log = log }
+}
+
+// WithTotalLabels replaces the labels that total rows are looked for under.  The default is DefaultTotalLabels.
+func WithTotalLabels(labels []string) Option {
+	return func(c *config) { c.totalLabels = labels }
+}
+
+// WithBreakdownPrefixes names the columns from breakdown tables after their tables.  See BreakdownPrefixes.
+func WithBreakdownPrefixes(prefixes BreakdownPrefixes) Option {
+	return func(c *config) { c.breakdownPrefixes = prefixes }
+}
+
+// WithRawHTML keeps the (sanitized) html each entry was parsed from, under RawHTMLKey.
+// It's never in the Columns.
+func WithRawHTML() Option {
+	return func(c *config) { c.rawHTML = true }
+}
+
+// WithSalvage parses a statement that looks truncated, instead of returning an error.
+// The last event gets low confidence, since it's probably missing things.
+func WithSalvage() Option {
+	return func(c *config) { c.salvage = true }
+}
+
+// WithScheduleContext is for statements saved as several files.
+// The distribution schedule starts as *schedule (for the tables before the first heading),
+// and whatever it is at the end is left in *schedule, for the next file.
+func WithScheduleContext(schedule *string) Option {
+	return func(c *config) { c.scheduleContext = schedule }
+}
+
+// WithDocumentOrder leaves the entries in the order they were in the statement, instead of sorting them by settlement date.
+func WithDocumentOrder() Option {
+	return func(c *config) { c.documentOrder = true }
+}
+
+// WithNumberFormat says which way round the statement writes its numbers, instead of working it out.
+func WithNumberFormat(format NumberFormat) Option {
+	return func(c *config) { c.numberFormat = format }
+}
+
+// WithDateLayouts replaces the layouts dates are read with, when sorting by them.  The default is DefaultDateLayouts.
+func WithDateLayouts(layouts DateLayouts) Option {
+	return func(c *config) { c.dateLayouts = layouts }
+}
+
+// WithDebug fills in the Statement's Debug, with the label/value pairs from every table as they were before normalization.  See DebugEvent.
+func WithDebug() Option {
+	return func(c *config) { c.debug = true }
+}
+
+// WithNormalizationHook calls fn every time a label is renamed to its normalized column name.
+func WithNormalizationHook(fn func(eventType, original, normalized string)) Option {
+	return func(c *config) { c.onNormalize = fn }
+}
+
+// WithTableTimingHook calls fn with how long each event table took to parse.
+func WithTableTimingHook(fn func(title string, d time.Duration)) Option {
+	return func(c *config) { c.onTableTiming = fn }
+}
+
+func (p *parser) timeTable(title string, start time.Time) {
+	if p.onTableTiming != nil {
+		p.onTableTiming(title, time.Since(start))
+	}
+}
+
+// Logger receives messages about the parse.  Trace is noisy; info is worth telling a person; warnings come with a code.
+type Logger interface {
+	Tracef(format string, args ...interface{})
+	Infof(format string, args ...interface{})
+	Warnf(code string, format string, args ...interface{})
+}
+
+// The codes of the warnings Parse can give.  (The command has more codes of its own; these are the same numbers.)
+const (
+	WarnUnparseableDate = "W001" // a settlement date we couldn't read.
+	WarnFallbackParser  = "W002" // the regular parser found nothing, and the positional fallback was used.
+	WarnTruncated       = "W011" // a file that looks cut off, parsed anyway with WithSalvage.
+)
+
+type nopLogger struct{}
+
+func (nopLogger) Tracef(format string, args ...interface{})             {}
+func (nopLogger) Infof(format string, args ...interface{})              {}
+func (nopLogger) Warnf(code string, format string, args ...interface{}) {}
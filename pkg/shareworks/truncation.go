@@ -0,0 +1,36 @@
+package shareworks
+
+import (
+	"bytes"
+)
+
+// Browsers sometimes give up partway through saving (or copying) a big statement.
+// The html parser doesn't mind -- it just closes whatever was left open -- so a truncated file parses fine,
+// and the last event silently comes out with half its values missing.  Not great.
+//
+// So we check for the tell-tale signs first: a table that was opened and never closed, or a tag cut off in the middle.
+// By default that's an error.  With WithSalvage (--salvage, on the command line), we carry on with whatever's there, and mark the last event as suspect.
+
+// truncationPoint says where a file looks to have been cut off.  Offset is in bytes; line is 1-based.
+type truncationPoint struct {
+	offset int
+	line   int
+	reason string
+}
+
+// detectTruncation looks for signs that the html was cut off before the end.
+func detectTruncation(bs []byte) (truncationPoint, bool) {
+	trimmed := bytes.TrimRight(bs, " \t\r\n")
+	lower := bytes.ToLower(trimmed)
+	if lastOpen, lastClose := bytes.LastIndexByte(lower, '<'), bytes.LastIndexByte(lower, '>'); lastOpen > lastClose {
+		return truncationAt(bs, lastOpen, "a tag is cut off in the middle"), true
+	}
+	if lastTable := bytes.LastIndex(lower, []byte("<table")); lastTable >= 0 && lastTable > bytes.LastIndex(lower, []byte("</table")) {
+		return truncationAt(bs, lastTable, "a table starting here is never closed"), true
+	}
+	return truncationPoint{}, false
+}
+
+func truncationAt(bs []byte, offset int, reason string) truncationPoint {
+	return truncationPoint{offset: offset, line: bytes.Count(bs[:offset], []byte("\n")) + 1, reason: reason}
+}
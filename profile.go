@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+	"time"
+)
+
+// For --profile: how long each input spent in each stage of the pipeline, and which tables took the longest to parse.
+// Nobody's statement should take long enough to care... but some people have a *lot* of years of statements.
+
+// The pipeline stages, in order.
+const (
+	stageParse     = "parse"     // reading the file and pulling events out of it.
+	stageDerive    = "derive"    // merging extra events, checks, same-day sales.
+	stageNormalize = "normalize" // renames, transforms, dropping columns.
+	stageEmit      = "emit"      // writing the output.
+)
+
+var profileStages = []string{stageParse, stageDerive, stageNormalize, stageEmit}
+
+// profileTimings is source -> stage -> time spent.  profileSources keeps the sources in the order they were first seen.
+var (
+	profileTimings = map[string]map[string]time.Duration{}
+	profileSources []string
+)
+
+// tableTiming is how long one event table took to parse.
+type tableTiming struct {
+	source   string
+	title    string
+	duration time.Duration
+}
+
+var tableTimings []tableTiming
+
+// slowTablesShown is how many of the slowest tables the report lists.
+const slowTablesShown = 5
+
+// noteTiming adds the time since start to a stage of a source.
+func noteTiming(source, stage string, start time.Time) {
+	if _, ok := profileTimings[source]; !ok {
+		profileTimings[source] = map[string]time.Duration{}
+		profileSources = append(profileSources, source)
+	}
+	profileTimings[source][stage] += time.Since(start)
+}
+
+func noteTableTiming(source, title string, d time.Duration) {
+	tableTimings = append(tableTimings, tableTiming{source, title, d})
+}
+
+// printProfileReport writes a table of the time spent per input and stage, and the slowest tables.
+func printProfileReport(wr io.Writer) {
+	fmt.Fprintf(wr, "Time spent in this run:\n")
+	tw := tabwriter.NewWriter(wr, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "  INPUT\t")
+	for _, stage := range profileStages {
+		fmt.Fprintf(tw, "%s\t", stage)
+	}
+	fmt.Fprintf(tw, "total\t\n")
+	for _, source := range profileSources {
+		var total time.Duration
+		fmt.Fprintf(tw, "  %s\t", source)
+		for _, stage := range profileStages {
+			d := profileTimings[source][stage]
+			total += d
+			fmt.Fprintf(tw, "%s\t", d.Round(time.Microsecond))
+		}
+		fmt.Fprintf(tw, "%s\t\n", total.Round(time.Microsecond))
+	}
+	tw.Flush()
+
+	if len(tableTimings) == 0 {
+		return
+	}
+	slowest := append([]tableTiming(nil), tableTimings...)
+	sort.SliceStable(slowest, func(i, j int) bool { return slowest[i].duration > slowest[j].duration })
+	if len(slowest) > slowTablesShown {
+		slowest = slowest[:slowTablesShown]
+	}
+	fmt.Fprintf(wr, "Slowest tables:\n")
+	tw = tabwriter.NewWriter(wr, 0, 0, 2, ' ', 0)
+	for _, t := range slowest {
+		fmt.Fprintf(tw, "  %s\t%s\t%q\n", t.duration.Round(time.Microsecond), t.source, t.title)
+	}
+	tw.Flush()
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/warpfork/shareworks-munger/pkg/shareworks"
+)
+
+// The qif output is an investment account in Quicken Interchange Format, for Quicken, Moneydance, and the like:
+// releases are ShrsIn (nothing was paid for them, so they mustn't take cash out of the account; the release price is their cost basis),
+// other buys (from --extra-events) are Buys, sales are Sells (with their fees as commission), transfers out are ShrsOut, and dividends are Divs.
+// Anything else is left out, with a warning each.
+//
+// Each schedule's shares are a security named after the schedule, or after its commodity in the --journal-config, if it has one.
+// QIF has no currencies; amounts are written as plain numbers, in whatever currency the statement had them.
+
+// qifDateLayout is how Quicken writes dates in the US, which is what QIF importers expect.
+const qifDateLayout = "01/02/2006"
+
+func emitQif(wr io.Writer, cfg journalConfig, names nameProfile, entries []map[string]string) error {
+	var sb strings.Builder
+	sb.WriteString("!Type:Invst\n")
+	for _, ent := range chronological(names, entries) {
+		security := ent["Distribution Schedule"]
+		if s := cfg.Schedules[security]; s.Commodity != "" {
+			security = s.Commodity
+		}
+		switch eventKind(ent) {
+		case "release", "purchase", "sale":
+			// Below.
+		case "payout":
+			date, err := parseDate(ent["Settlement Date:"])
+			if err != nil {
+				warnf(warnQifSkipped, "leaving event %q out of the qif: no usable settlement date: %v", ent["Event"], err)
+				continue
+			}
+			// What was paid out is the total of the breakdown, after any tax was withheld.
+			totalColumn := cfg.breakdownPrefixes.Total("Dividend Breakdown", "Dividend Breakdown Total")
+			amount, _, err := shareworks.ParseEntryMoney(ent, ent[totalColumn])
+			if err != nil {
+				warnf(warnQifSkipped, "leaving event %q out of the qif: no usable %q: %v", ent["Event"], totalColumn, err)
+				continue
+			}
+			fmt.Fprintf(&sb, "D%s\nNDiv\nY%s\nT%s\nM%s\n^\n", date.Format(qifDateLayout), security, formatJournalMoney(amount), ent["Event"])
+			continue
+		case "transfer":
+			date, err := parseDate(ent["Settlement Date:"])
+			if err != nil {
+				warnf(warnQifSkipped, "leaving event %q out of the qif: no usable settlement date: %v", ent["Event"], err)
+				continue
+			}
+			shares, err := parseShareCount(ent, ent["stocks report"])
+			if err != nil {
+				warnf(warnQifSkipped, "leaving event %q out of the qif: no usable share count: %v", ent["Event"], err)
+				continue
+			}
+			fmt.Fprintf(&sb, "D%s\nNShrsOut\nY%s\nQ%s\nM%s\n^\n", date.Format(qifDateLayout), security, formatShareCount(shares), ent["Event"])
+			continue
+		default:
+			warnf(warnQifSkipped, "leaving event %q out of the qif: there's no kind of qif transaction for it", ent["Event"])
+			continue
+		}
+		ev, whyNot := readJournalEvent(cfg, ent)
+		if whyNot != "" {
+			warnf(warnQifSkipped, "leaving event %q out of the qif: %s", ent["Event"], whyNot)
+			continue
+		}
+		fmt.Fprintf(&sb, "D%s\n", ev.date.Format(qifDateLayout))
+		switch ev.kind {
+		case "release":
+			sb.WriteString("NShrsIn\n")
+		case "purchase":
+			sb.WriteString("NBuy\n")
+		case "sale":
+			sb.WriteString("NSell\n")
+		}
+		fmt.Fprintf(&sb, "Y%s\n", security)
+		fmt.Fprintf(&sb, "I%s\n", formatJournalMoney(ev.price))
+		fmt.Fprintf(&sb, "Q%s\n", formatShareCount(ev.shares))
+		if ev.kind == "sale" {
+			// A sale's total is what was paid out: the gross proceeds, less the commission.
+			if fees := ev.fees(); fees != 0 {
+				fmt.Fprintf(&sb, "O%s\n", formatJournalMoney(fees))
+			}
+			fmt.Fprintf(&sb, "T%s\n", formatJournalMoney(ev.net))
+		} else {
+			// For a purchase, that's what was paid; for a release, it's the cost basis.
+			fmt.Fprintf(&sb, "T%s\n", formatJournalMoney(ev.shares*ev.price))
+		}
+		fmt.Fprintf(&sb, "M%s\n", ent["Event"])
+		sb.WriteString("^\n")
+	}
+	if _, err := io.WriteString(wr, sb.String()); err != nil {
+		return fmt.Errorf("error while emitting qif: %w", err)
+	}
+	return nil
+}
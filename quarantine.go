@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// After a big batch run, the files that failed are easy to lose in all the other output.
+// With --quarantine-dir, every input that couldn't be parsed is copied into that directory,
+// along with a report (quarantine.txt) saying what was wrong with each.
+// That way they can be looked into, or retried with different flags, by pointing the munger at that directory.
+//
+// The inputs are copied, not moved: the munger never changes its input files.
+// Stdin can't be copied anywhere after the fact, so it only gets a line in the report.
+
+const quarantineReportName = "quarantine.txt"
+
+type quarantine struct {
+	dir    string
+	names  outputNames
+	report []string
+}
+
+// add copies a failed input into the quarantine directory, and notes why it failed.
+func (q *quarantine) add(filename string, failure error) error {
+	if q.names == nil {
+		q.names = outputNames{}
+		// The report isn't an input, so it can't have its name taken.
+		q.names.claim(filepath.Join(q.dir, quarantineReportName))
+		if err := os.MkdirAll(q.dir, 0755); err != nil {
+			return fmt.Errorf("failed to create quarantine directory: %w", err)
+		}
+	}
+	if filename == stdinFilename {
+		q.report = append(q.report, fmt.Sprintf("(stdin): %s", failure))
+		return nil
+	}
+	// Nothing to copy if the file isn't there at all.
+	fi, err := os.Stat(filename)
+	if err != nil {
+		q.report = append(q.report, fmt.Sprintf("%s: %s", filename, failure))
+		return nil
+	}
+	dest := q.names.claim(filepath.Join(q.dir, safeFilename(filepath.Base(filename))))
+	// If the quarantine directory is where the input already is, it's already there; copying it over itself would empty it.
+	if destFi, err := os.Stat(dest); err == nil && os.SameFile(fi, destFi) {
+		q.report = append(q.report, fmt.Sprintf("%s: %s", filepath.Base(dest), failure))
+		return nil
+	}
+	if err := copyFile(filename, dest); err != nil {
+		return fmt.Errorf("failed to quarantine %q: %w", filename, err)
+	}
+	q.report = append(q.report, fmt.Sprintf("%s (from %s): %s", filepath.Base(dest), filename, failure))
+	return nil
+}
+
+// writeReport writes the report of everything quarantined in this run.  If nothing was, there's no report.
+func (q *quarantine) writeReport() error {
+	if len(q.report) == 0 {
+		return nil
+	}
+	filename := filepath.Join(q.dir, quarantineReportName)
+	if err := ioutil.WriteFile(filename, []byte(strings.Join(q.report, "\n")+"\n"), 0644); err != nil {
+		return fmt.Errorf("failed to write quarantine report: %w", err)
+	}
+	infof("%d input(s) failed; see %q for why.", len(q.report), filename)
+	return nil
+}
+
+func copyFile(from, to string) error {
+	in, err := os.Open(from)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(to)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
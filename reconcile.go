@@ -0,0 +1,175 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/warpfork/shareworks-munger/pkg/shareworks"
+)
+
+// reconcileBalances walks the (already sorted) entries and keeps a running share balance per distribution schedule,
+// adding the shares from each "Buy" and taking away the shares from each "Sell".
+// It warns if a sell takes more shares than the schedule has seen come in,
+// or if a sell leaves a fractional share behind.
+//
+// Either of those usually means events are landing under the wrong schedule
+// (or you've got manually-entered events that don't use quite the same schedule name as the Shareworks data).
+// It can also just mean the statement doesn't go back far enough to see where the shares came from, though!
+// So these are warnings, not errors.
+//
+// Some plans report events for the same schedule in more than one currency (USD- and CAD-denominated units, say).
+// Those aren't the same shares, so a schedule like that gets a separate balance per currency (going by the price per unit).
+func reconcileBalances(entries []map[string]string) {
+	balanceKeys := balanceKeysByCurrency(entries)
+	balances := map[string]float64{}
+	for i, ent := range entries {
+		schedule := balanceKeys[i]
+		sharesText, ok := ent["stocks report"]
+		if !ok {
+			continue
+		}
+		shares, err := parseShareCount(ent, sharesText)
+		if err != nil {
+			warnf(warnUnparseableShareCount, "Could not parse share count %q in event %q: %v", sharesText, ent["Event"], err)
+			continue
+		}
+		switch ent["Type"] {
+		case "Buy":
+			balances[schedule] += shares
+		case "Sell":
+			if shares > balances[schedule]+shareEpsilon {
+				warnf(warnOversold, "event %q sells %s shares, but only %s have been seen coming in for distribution schedule %q -- is this event under the right schedule?",
+					ent["Event"], sharesText, formatShareCount(balances[schedule]), schedule)
+			}
+			balances[schedule] -= shares
+			if remainder := balances[schedule] - math.Round(balances[schedule]); math.Abs(remainder) > shareEpsilon {
+				warnf(warnFractionalBalance, "event %q leaves a fractional balance of %s shares for distribution schedule %q",
+					ent["Event"], formatShareCount(balances[schedule]), schedule)
+			}
+		}
+	}
+}
+
+// balanceKeysByCurrency says which balance each entry counts against.
+// Usually that's just the distribution schedule.  But for a schedule with events in more than one currency,
+// it's the schedule plus the currency, like "2021 RSU Plan (CAD)".
+// Events with no clear currency of their own (a bare "$") count against "2021 RSU Plan (unknown currency)" in that case.
+func balanceKeysByCurrency(entries []map[string]string) []string {
+	currencies := make([]string, len(entries))
+	seen := map[string]map[string]bool{} // schedule -> currencies.
+	for i, ent := range entries {
+		schedule := ent["Distribution Schedule"]
+		if _, currency, err := shareworks.ParseEntryMoney(ent, ent["price per unit"]); err == nil && currency != "" {
+			currencies[i] = currency
+			if seen[schedule] == nil {
+				seen[schedule] = map[string]bool{}
+			}
+			seen[schedule][currency] = true
+		}
+	}
+	keys := make([]string, len(entries))
+	for i, ent := range entries {
+		schedule := ent["Distribution Schedule"]
+		switch {
+		case len(seen[schedule]) <= 1:
+			keys[i] = schedule
+		case currencies[i] == "":
+			keys[i] = schedule + " (unknown currency)"
+		default:
+			keys[i] = schedule + " (" + currencies[i] + ")"
+		}
+	}
+	return keys
+}
+
+// shareEpsilon is how much slop we'll allow in share arithmetic before calling something fractional.
+const shareEpsilon = 1e-6
+
+// parseShareCount reads a share count from ent, going by however ent's statement writes its numbers.
+func parseShareCount(ent map[string]string, s string) (float64, error) {
+	s = strings.TrimSpace(s)
+	if shareworks.NumberFormatOf(ent) == shareworks.DecimalComma {
+		s = strings.NewReplacer(".", "", " ", "", ",", ".").Replace(s)
+	} else {
+		s = strings.ReplaceAll(s, ",", "")
+	}
+	return strconv.ParseFloat(s, 64)
+}
+
+func formatShareCount(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// checkThresholds warns about any event with a money value over maxValue, or a share count over maxShares.
+// (Either limit can be zero, which means don't check it.)
+// Absurd numbers are the usual sign that a label got paired with the wrong value cell somewhere.
+// It also traces how many events each distribution schedule ended up with, which is handy for the same reason.
+func checkThresholds(entries []map[string]string, columns []string, maxValue, maxShares float64) {
+	counts := map[string]int{}
+	var schedules []string
+	for _, ent := range entries {
+		schedule := ent["Distribution Schedule"]
+		if _, ok := counts[schedule]; !ok {
+			schedules = append(schedules, schedule)
+		}
+		counts[schedule]++
+
+		if maxShares > 0 {
+			if shares, err := parseShareCount(ent, ent["stocks report"]); err == nil && shares > maxShares {
+				warnf(warnSharesOverLimit, "event %q has %s shares, which is over the limit of %s -- check that the row was parsed correctly", ent["Event"], ent["stocks report"], formatShareCount(maxShares))
+			}
+		}
+		if maxValue > 0 {
+			for _, col := range columns {
+				// Share counts like "1,234.00" look like money too, but they've got their own limit.
+				if strings.HasPrefix(col, "stocks report") {
+					continue
+				}
+				value, ok := ent[col]
+				if !ok || !shareworks.LooksLikeMoney(value) {
+					continue
+				}
+				if amount, _, err := shareworks.ParseEntryMoney(ent, value); err == nil && math.Abs(amount) > maxValue {
+					warnf(warnValueOverLimit, "event %q has %s in column %q, which is over the limit of %s -- check that the row was parsed correctly", ent["Event"], value, col, formatShareCount(maxValue))
+				}
+			}
+		}
+	}
+	for _, schedule := range schedules {
+		tracef("distribution schedule %q: %d events", schedule, counts[schedule])
+	}
+}
+
+// eventDateColumns are the dates inside an event's table that should be close to the date in its title.
+var eventDateColumns = []string{"Release Date:", "Trade Date:", "Settlement Date:"}
+
+// checkEventDates warns about (and lowers the confidence of) events where the date in the title
+// is more than toleranceDays away from the dates inside the table.
+// Settlement lag means they won't always match exactly, but they shouldn't be weeks apart;
+// if they are, some table probably got associated with the wrong event.
+func checkEventDates(columns *[]string, entries []map[string]string, toleranceDays int) {
+	tolerance := time.Duration(toleranceDays) * 24 * time.Hour
+	for _, ent := range entries {
+		eventDate, err := parseDate(ent["Event Date"])
+		if err != nil {
+			continue
+		}
+		for _, col := range eventDateColumns {
+			text, ok := ent[col]
+			if !ok {
+				continue
+			}
+			date, err := parseDate(text)
+			if err != nil {
+				continue
+			}
+			if diff := date.Sub(eventDate); diff > tolerance || diff < -tolerance {
+				warnf(warnEventDateMismatch, "event %q has %s %s, which is more than %d days from the date in its title -- the tables may have been mismatched", ent["Event"], strings.TrimSuffix(col, ":"), text, toleranceDays)
+				shareworks.Doubt(columns, ent, shareworks.ConfidenceMedium, fmt.Sprintf("%s doesn't match the title date", strings.TrimSuffix(col, ":")))
+			}
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/warpfork/shareworks-munger/pkg/shareworks"
+)
+
+// A common election is "sell everything as soon as it vests".
+// That shows up as a Release, and then a Withdrawal of the very same shares settling the same day.
+// If you naively add up both rows, you'll count that value twice: once as income, and once as proceeds.
+//
+// linkSameDaySales finds those pairs.  In "link" mode, each row of a pair gets a "Linked Event" column naming the other.
+// In "collapse" mode, the withdrawal's values get folded into the release row (as "Same-Day Sale: ..." columns),
+// and the withdrawal row goes away.
+func linkSameDaySales(columns []string, entries []map[string]string, mode string) ([]string, []map[string]string) {
+	paired := map[int]bool{} // indexes of withdrawals that already got matched up with a release.
+	for _, release := range entries {
+		if release["Type"] != "Buy" {
+			continue
+		}
+		for j, sale := range entries {
+			if paired[j] || !isSameDayFullSale(release, sale) {
+				continue
+			}
+			switch mode {
+			case "link":
+				shareworks.Accumulate(&columns, release, "Linked Event", sale["Event"])
+				shareworks.Accumulate(&columns, sale, "Linked Event", release["Event"])
+			case "collapse":
+				for _, col := range columns {
+					if v, ok := sale[col]; ok && col != "Distribution Schedule" && col != "Type" {
+						shareworks.Accumulate(&columns, release, "Same-Day Sale: "+strings.TrimSuffix(col, ":"), v)
+					}
+				}
+			}
+			paired[j] = true
+			tracef("event %q looks like a same-day sale of release %q", sale["Event"], release["Event"])
+			break
+		}
+	}
+	if mode != "collapse" {
+		return columns, entries
+	}
+	var result []map[string]string
+	for j, ent := range entries {
+		if !paired[j] {
+			result = append(result, ent)
+		}
+	}
+	return columns, result
+}
+
+func isSameDayFullSale(release, sale map[string]string) bool {
+	if sale["Type"] != "Sell" {
+		return false
+	}
+	if sale["Distribution Schedule"] != release["Distribution Schedule"] {
+		return false
+	}
+	if release["Settlement Date:"] == "" || sale["Settlement Date:"] != release["Settlement Date:"] {
+		return false
+	}
+	released, err := parseShareCount(release, release["stocks report"])
+	if err != nil {
+		return false
+	}
+	sold, err := parseShareCount(sale, sale["stocks report"])
+	if err != nil {
+		return false
+	}
+	return released-sold < shareEpsilon && sold-released < shareEpsilon
+}
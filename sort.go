@@ -0,0 +1,176 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/warpfork/shareworks-munger/pkg/shareworks"
+)
+
+// Events are in settlement date order by default, which is what the share balance checks need.
+// --sort changes the order of the output, once the checks are done:
+// `--sort="Trade Date:,desc"` for the newest sales first, or `--sort="Settlement Date:" --sort=Event` to break ties by title.
+// `--sort=none` puts the events back in the order they were in in the statement (or the order of the files, for several).
+//
+// Values are compared as dates if they're both statement dates, as numbers if they're both numbers or money, and as text otherwise.
+// Events missing a value for the column go last, whichever way it's sorted.
+// So do values in a column of money that don't read as an amount, with a warning (W015) for each.
+// The sort is stable, so anything that compares equal on every key stays in settlement date order.
+
+// documentOrderKey is where an entry remembers what order it was read in.
+// Like the raw html, it's never in the column order, so it's never emitted.
+const documentOrderKey = "Document Order"
+
+// documentOrderNext is the document order for the next entry read.  It keeps counting across input files, so merged events keep their files' order too.
+var documentOrderNext int
+
+// noteDocumentOrder stamps the entries with the order they're in.
+func noteDocumentOrder(entries []map[string]string) {
+	for _, ent := range entries {
+		ent[documentOrderKey] = strconv.Itoa(documentOrderNext)
+		documentOrderNext++
+	}
+}
+
+type sortKey struct {
+	column     string
+	descending bool
+}
+
+// sortKeys implements flag.Value, so --sort can be given several times.
+// No keys means the usual order; "none" is a key of its own, for document order.
+type sortKeys []sortKey
+
+func (l *sortKeys) String() string {
+	var parts []string
+	for _, k := range *l {
+		if k.descending {
+			parts = append(parts, k.column+",desc")
+		} else {
+			parts = append(parts, k.column)
+		}
+	}
+	return strings.Join(parts, "; ")
+}
+
+func (l *sortKeys) Set(s string) error {
+	k := sortKey{column: s}
+	if comma := strings.LastIndex(s, ","); comma >= 0 {
+		switch strings.ToLower(strings.TrimSpace(s[comma+1:])) {
+		case "asc":
+			k.column = s[:comma]
+		case "desc":
+			k.column, k.descending = s[:comma], true
+		default:
+			return fmt.Errorf("sort %q should look like COLUMN, COLUMN,asc, or COLUMN,desc", s)
+		}
+	}
+	k.column = strings.TrimSpace(k.column)
+	if k.column == "" {
+		return fmt.Errorf("sort %q needs a column name", s)
+	}
+	if k.column == "none" && k.descending {
+		return fmt.Errorf("--sort=none has no direction")
+	}
+	if len(*l) > 0 && (k.column == "none" || (*l)[0].column == "none") {
+		return fmt.Errorf("--sort=none can't be combined with other sort columns")
+	}
+	*l = append(*l, k)
+	return nil
+}
+
+// apply returns the entries in the order the keys say.  The slice given is left alone.
+func (l sortKeys) apply(entries []map[string]string) []map[string]string {
+	keys := l
+	if len(keys) == 1 && keys[0].column == "none" {
+		keys = sortKeys{{column: documentOrderKey}}
+	}
+	// The values to sort on, by key and then by entry; a money value that won't parse is blanked out, so it goes last too.
+	values := make([][]string, len(keys))
+	for ki, k := range keys {
+		values[ki] = make([]string, len(entries))
+		// It's a column of money if at least half of the values in it look like money; otherwise the text ones are just text.
+		present, money := 0, 0
+		for i, ent := range entries {
+			values[ki][i] = ent[k.column]
+			if ent[k.column] != "" {
+				present++
+			}
+			if shareworks.LooksLikeMoney(ent[k.column]) {
+				money++
+			}
+		}
+		if money*2 < present {
+			continue
+		}
+		for i, ent := range entries {
+			v := values[ki][i]
+			if v == "" {
+				continue
+			}
+			if _, _, err := shareworks.ParseEntryMoney(ent, v); err != nil {
+				warnf(warnUnsortableMoney, "event %q has %q in %q, which doesn't read as an amount, so it's sorted last", ent["Event"], v, k.column)
+				values[ki][i] = ""
+			}
+		}
+	}
+	order := make([]int, len(entries))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		for ki, k := range keys {
+			a, b := values[ki][order[i]], values[ki][order[j]]
+			// Missing values go last either way.
+			if a == "" || b == "" {
+				if a == b {
+					continue
+				}
+				return b == ""
+			}
+			c := compareValues(entries[order[i]], a, entries[order[j]], b)
+			if c == 0 {
+				continue
+			}
+			if k.descending {
+				return c > 0
+			}
+			return c < 0
+		}
+		return false
+	})
+	result := make([]map[string]string, len(entries))
+	for i, o := range order {
+		result[i] = entries[o]
+	}
+	return result
+}
+
+// compareValues compares two values (from entries entA and entB) as dates, numbers, or text, whichever they both are.
+func compareValues(entA map[string]string, a string, entB map[string]string, b string) int {
+	if da, err := parseDate(a); err == nil {
+		if db, err := parseDate(b); err == nil {
+			switch {
+			case da.Before(db):
+				return -1
+			case da.After(db):
+				return 1
+			}
+			return 0
+		}
+	}
+	if na, _, err := shareworks.ParseEntryMoney(entA, a); err == nil {
+		if nb, _, err := shareworks.ParseEntryMoney(entB, b); err == nil {
+			switch {
+			case na < nb:
+				return -1
+			case na > nb:
+				return 1
+			}
+			return 0
+		}
+	}
+	return strings.Compare(a, b)
+}
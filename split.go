@@ -0,0 +1,93 @@
+package main
+
+import (
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// With --split-by, each output is broken up into several files, instead of one:
+// "--split-by=year" makes one per tax year, and "--split-by=schedule" one per distribution schedule (so each kind of share can go into its own tracker).
+// The part goes on the end of the output filename, before the extension: "--output=shareworks.csv" gives "shareworks-2023.csv", "shareworks-2024.csv", and so on.
+//
+// The splitting happens after the checks, so a sale in one year still gets checked against the releases from the years before it;
+// and before the renames and transforms, so it goes by the statement's own columns, whatever they end up called.
+
+// splitPart is some of the entries from a batch, that get a file of their own.
+type splitPart struct {
+	suffix  string // goes on the end of the output filename, like "2023".  Empty when not splitting.
+	entries []map[string]string
+}
+
+// undatedPart is the suffix for entries that can't be put in a year, because they don't have a settlement date (or it's not one we can read).
+const undatedPart = "undated"
+
+// unscheduledPart is the suffix for entries that weren't under any distribution schedule heading.
+const unscheduledPart = "unscheduled"
+
+// splitEntries breaks entries up by splitBy.  The order of the entries within each part is left alone.
+// With no splitBy, it's all one part.
+func splitEntries(splitBy string, entries []map[string]string) []splitPart {
+	switch splitBy {
+	case "":
+		return []splitPart{{entries: entries}}
+	case "year":
+		parts := bucketEntries(entries, func(ent map[string]string) string {
+			date, err := parseDate(ent["Settlement Date:"])
+			if err != nil {
+				tracef("split: event %q has no settlement date we can read, so it goes in %q", ent["Event"], undatedPart)
+				return undatedPart
+			}
+			return strconv.Itoa(date.Year())
+		})
+		// Oldest first; and the undated ones last, since "undated" sorts after digits anyway.
+		sort.SliceStable(parts, func(i, j int) bool { return parts[i].suffix < parts[j].suffix })
+		return parts
+	case "schedule":
+		// These stay in the order they were first seen in, which is near enough the order the plans started in.
+		return bucketEntries(entries, func(ent map[string]string) string {
+			if ent["Distribution Schedule"] == "" {
+				return unscheduledPart
+			}
+			return ent["Distribution Schedule"]
+		})
+	default:
+		panic("unreachable, --split-by values are checked when parsing flags")
+	}
+}
+
+// bucketEntries breaks entries up by whatever key says, with the parts in the order their keys were first seen.
+func bucketEntries(entries []map[string]string, key func(ent map[string]string) string) []splitPart {
+	var parts []splitPart
+	index := map[string]int{}
+	for _, ent := range entries {
+		k := key(ent)
+		i, ok := index[k]
+		if !ok {
+			i = len(parts)
+			index[k] = i
+			parts = append(parts, splitPart{suffix: k})
+		}
+		parts[i].entries = append(parts[i].entries, ent)
+	}
+	return parts
+}
+
+// splitFilename puts a split part's suffix on the end of an output filename, before the extension (all of it, for ".csv.gz").
+func splitFilename(filename string, suffix string) string {
+	if suffix == "" {
+		return filename
+	}
+	stem, ext := splitExtension(filename)
+	return stem + "-" + safeFilename(suffix) + ext
+}
+
+// splitExtension splits a filename into its stem and its extension, counting ".csv.gz" as one extension.
+func splitExtension(filename string) (stem, ext string) {
+	ext = filepath.Ext(filename)
+	if strings.HasSuffix(filename, ".gz") {
+		ext = filepath.Ext(strings.TrimSuffix(filename, ".gz")) + ".gz"
+	}
+	return strings.TrimSuffix(filename, ext), ext
+}
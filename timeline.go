@@ -0,0 +1,194 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/warpfork/shareworks-munger/pkg/shareworks"
+)
+
+// The timeline is a single self-contained html page with an svg in it:
+// one horizontal lane per distribution schedule, and one dot per event,
+// placed by settlement date and sized by value (shares times price per unit).
+// Hovering a dot shows the event details.
+//
+// It's not meant to be pretty.  It's meant to make things like "wait, why is there nothing in March?"
+// or "why are there two identical dots there?" jump out at you.
+
+const (
+	timelineWidth      = 1000
+	timelineMargin     = 180 // room for lane labels on the left.
+	timelineLaneHeight = 60
+	timelineMaxRadius  = 20
+	timelineMinRadius  = 3
+)
+
+type timelinePoint struct {
+	X, Y, R float64
+	Color   string
+	Tooltip string
+}
+
+type timelineLane struct {
+	Label string
+	Y     float64
+}
+
+type timelineTick struct {
+	X     float64
+	Label string
+}
+
+type timelineData struct {
+	Width, Height float64
+	Lanes         []timelineLane
+	Ticks         []timelineTick
+	Points        []timelinePoint
+}
+
+func emitTimelineHtml(wr io.Writer, columnOrder []string, entries []map[string]string) error {
+	// First pass: figure out dates and values and lanes.
+	type placed struct {
+		ent   map[string]string
+		date  time.Time
+		value float64
+	}
+	var events []placed
+	laneIndex := map[string]int{}
+	var lanes []timelineLane
+	var first, last time.Time
+	maxValue := 0.0
+	for _, ent := range entries {
+		date, err := parseDate(ent["Settlement Date:"])
+		if err != nil {
+			warnf(warnTimelineNoDate, "leaving event %q off the timeline: no usable settlement date: %v", ent["Event"], err)
+			continue
+		}
+		schedule := ent["Distribution Schedule"]
+		if _, ok := laneIndex[schedule]; !ok {
+			laneIndex[schedule] = len(lanes)
+			lanes = append(lanes, timelineLane{Label: schedule})
+		}
+		value := eventValue(ent)
+		if value > maxValue {
+			maxValue = value
+		}
+		if first.IsZero() || date.Before(first) {
+			first = date
+		}
+		if last.IsZero() || date.After(last) {
+			last = date
+		}
+		events = append(events, placed{ent, date, value})
+	}
+	if len(events) == 0 {
+		return fmt.Errorf("no events with settlement dates to put on a timeline")
+	}
+	// Pad the ends out to whole months, so the first and last dots aren't jammed against the edges.
+	first = time.Date(first.Year(), first.Month(), 1, 0, 0, 0, 0, time.UTC)
+	last = time.Date(last.Year(), last.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+	span := last.Sub(first).Seconds()
+	xOf := func(t time.Time) float64 {
+		return timelineMargin + (timelineWidth-timelineMargin-timelineMaxRadius)*t.Sub(first).Seconds()/span
+	}
+
+	data := timelineData{
+		Width:  timelineWidth,
+		Height: float64(len(lanes)+1) * timelineLaneHeight,
+	}
+	for i := range lanes {
+		lanes[i].Y = float64(i+1) * timelineLaneHeight
+	}
+	data.Lanes = lanes
+	for t := first; !t.After(last); t = t.AddDate(0, 1, 0) {
+		label := ""
+		if t.Month() == time.January || t.Equal(first) {
+			label = t.Format("Jan 2006")
+		}
+		data.Ticks = append(data.Ticks, timelineTick{X: xOf(t), Label: label})
+	}
+	for _, ev := range events {
+		r := float64(timelineMinRadius)
+		if maxValue > 0 && ev.value > 0 {
+			// Area proportional to value.
+			r = math.Max(r, timelineMaxRadius*math.Sqrt(ev.value/maxValue))
+		}
+		color := "#888888"
+		switch ev.ent["Type"] {
+		case "Buy":
+			color = "#2a9d4a"
+		case "Sell":
+			color = "#d1495b"
+		case "Dividend":
+			color = "#3a6ea5"
+		}
+		var tooltip strings.Builder
+		for _, col := range columnOrder {
+			if v, ok := ev.ent[col]; ok {
+				fmt.Fprintf(&tooltip, "%s: %s\n", strings.TrimSuffix(col, ":"), v)
+			}
+		}
+		data.Points = append(data.Points, timelinePoint{
+			X:       xOf(ev.date),
+			Y:       lanes[laneIndex[ev.ent["Distribution Schedule"]]].Y,
+			R:       r,
+			Color:   color,
+			Tooltip: strings.TrimSpace(tooltip.String()),
+		})
+	}
+	// Draw the big dots first, so the little ones end up on top and stay hoverable.
+	sort.SliceStable(data.Points, func(i, j int) bool { return data.Points[i].R > data.Points[j].R })
+
+	return timelineTemplate.Execute(wr, data)
+}
+
+// eventValue is shares times price per unit, or zero if we can't make sense of either.
+func eventValue(ent map[string]string) float64 {
+	shares, err := parseShareCount(ent, ent["stocks report"])
+	if err != nil {
+		return 0
+	}
+	price, _, err := shareworks.ParseEntryMoney(ent, ent["price per unit"])
+	if err != nil {
+		return 0
+	}
+	return shares * price
+}
+
+var timelineTemplate = template.Must(template.New("timeline").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Shareworks event timeline</title>
+<style>
+body { font-family: sans-serif; }
+circle { fill-opacity: 0.6; stroke: #333333; stroke-width: 1; }
+circle:hover { fill-opacity: 1; }
+.lane { stroke: #dddddd; }
+.tick { stroke: #eeeeee; }
+</style>
+</head>
+<body>
+<h1>Shareworks event timeline</h1>
+<p>Green is a release (buy), red is a withdrawal (sell), blue is a dividend.  Dot area is shares times price per unit.  Hover a dot for details.</p>
+<svg width="{{.Width}}" height="{{.Height}}" xmlns="http://www.w3.org/2000/svg">
+{{- range .Ticks}}
+<line class="tick" x1="{{.X}}" y1="0" x2="{{.X}}" y2="{{$.Height}}"/>
+{{- if .Label}}<text x="{{.X}}" y="{{$.Height}}" font-size="11" dy="-4">{{.Label}}</text>{{end}}
+{{- end}}
+{{- range .Lanes}}
+<line class="lane" x1="0" y1="{{.Y}}" x2="{{$.Width}}" y2="{{.Y}}"/>
+<text x="4" y="{{.Y}}" font-size="12" dy="-6">{{.Label}}</text>
+{{- end}}
+{{- range .Points}}
+<circle cx="{{.X}}" cy="{{.Y}}" r="{{.R}}" fill="{{.Color}}"><title>{{.Tooltip}}</title></circle>
+{{- end}}
+</svg>
+</body>
+</html>
+`))
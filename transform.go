@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/warpfork/shareworks-munger/pkg/shareworks"
+)
+
+// Transforms are little per-column adjustments applied right before emitting,
+// for whatever quirks the program you're importing into has.
+// They're given on the command line as "COLUMN=TRANSFORM", or "COLUMN=TRANSFORM:ARGUMENT", and can be repeated.
+// For example: `--transform="stocks report=negate-sells" --transform="Settlement Date:=date:2006-01-02"`.
+//
+// The transforms are:
+//   - "upper" and "lower": change the case.
+//   - "strip-prefix:PREFIX": remove PREFIX from the start of the value, if it's there.
+//   - "date:LAYOUT": reformat a statement date.  The layout is written the way Go does it: as the date 2006-01-02 would look.
+//   - "negate-sells": make the value negative for "Sell" events (e.g. so share counts add up to a balance).
+//     Values that were negative already, with a minus sign or in parentheses, become positive.
+
+type columnTransform struct {
+	column string
+	name   string
+	arg    string
+}
+
+func (t columnTransform) apply(row map[string]string, value string) (string, error) {
+	switch t.name {
+	case "upper":
+		return strings.ToUpper(value), nil
+	case "lower":
+		return strings.ToLower(value), nil
+	case "strip-prefix":
+		return strings.TrimPrefix(value, t.arg), nil
+	case "date":
+		date, err := parseDate(value)
+		if err != nil {
+			return value, err
+		}
+		return date.Format(t.arg), nil
+	case "negate-sells":
+		if row["Type"] != "Sell" {
+			return value, nil
+		}
+		if strings.HasPrefix(value, "-") {
+			return strings.TrimPrefix(value, "-"), nil
+		}
+		// Accounting style: "(42.50)" is already negative.
+		if strings.HasPrefix(value, "(") && strings.HasSuffix(value, ")") {
+			return strings.TrimSpace(value[1 : len(value)-1]), nil
+		}
+		if _, err := parseShareCount(row, value); err != nil {
+			// Something with a currency on it, probably.  Still fine to stick a minus sign on the front.
+			if _, _, err := shareworks.ParseEntryMoney(row, value); err != nil {
+				return value, err
+			}
+		}
+		return "-" + value, nil
+	default:
+		panic("unreachable, transform names are checked when parsing flags")
+	}
+}
+
+// transformList implements flag.Value, so --transform can be given several times.
+type transformList []columnTransform
+
+func (l *transformList) String() string {
+	var parts []string
+	for _, t := range *l {
+		parts = append(parts, t.column+"="+t.name)
+	}
+	return strings.Join(parts, ", ")
+}
+
+func (l *transformList) Set(s string) error {
+	eq := strings.Index(s, "=")
+	if eq < 1 {
+		return fmt.Errorf("transform %q should look like COLUMN=TRANSFORM", s)
+	}
+	t := columnTransform{column: s[:eq]}
+	t.name, t.arg = s[eq+1:], ""
+	if colon := strings.Index(t.name, ":"); colon >= 0 {
+		t.name, t.arg = t.name[:colon], t.name[colon+1:]
+	}
+	switch t.name {
+	case "upper", "lower", "negate-sells":
+	case "strip-prefix", "date":
+		if t.arg == "" {
+			return fmt.Errorf("transform %q needs an argument, like %s:SOMETHING", s, t.name)
+		}
+	default:
+		return fmt.Errorf("unknown transform %q in %q -- should be one of upper, lower, strip-prefix, date, negate-sells", t.name, s)
+	}
+	*l = append(*l, t)
+	return nil
+}
+
+// dateColumns is the columns that a "date:" transform reformats.
+func (l transformList) dateColumns() map[string]bool {
+	columns := map[string]bool{}
+	for _, t := range l {
+		if t.name == "date" {
+			columns[t.column] = true
+		}
+	}
+	return columns
+}
+
+// apply returns transformed copies of the entries.  The originals are left alone.
+func (l transformList) apply(entries []map[string]string) []map[string]string {
+	if len(l) == 0 {
+		return entries
+	}
+	result := make([]map[string]string, len(entries))
+	for i, ent := range entries {
+		row := make(map[string]string, len(ent))
+		for k, v := range ent {
+			row[k] = v
+		}
+		for _, t := range l {
+			value, ok := row[t.column]
+			if !ok {
+				continue
+			}
+			transformed, err := t.apply(ent, value)
+			if err != nil {
+				warnf(warnTransformFailed, "transform %s on column %q of event %q: %v (leaving it as-is)", t.name, t.column, ent["Event"], err)
+				continue
+			}
+			row[t.column] = transformed
+		}
+		result[i] = row
+	}
+	return result
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Every warning has a code, so that once you've looked into one and decided it's fine for your statements
+// (say, the statement doesn't go back far enough to see where some shares came from),
+// you can --suppress-warning it, and still see anything new.
+//
+// Codes are forever: don't renumber them, and don't reuse the number of one that goes away.
+// The list in the README should match this one.
+
+type warningCode string
+
+const (
+	warnUnparseableDate       warningCode = "W001" // a settlement date we couldn't read.
+	warnFallbackParser        warningCode = "W002" // the regular parser found nothing, and the positional fallback was used.
+	warnUnparseableShareCount warningCode = "W003" // a share count we couldn't read.
+	warnOversold              warningCode = "W004" // a sale of more shares than the schedule has seen come in.
+	warnFractionalBalance     warningCode = "W005" // a sale that leaves a fractional share behind.
+	warnSharesOverLimit       warningCode = "W006" // --warn-shares-over.
+	warnValueOverLimit        warningCode = "W007" // --warn-value-over.
+	warnEventDateMismatch     warningCode = "W008" // a date too far from the one in the event's title.
+	warnTimelineNoDate        warningCode = "W009" // an event left off the timeline.
+	warnTransformFailed       warningCode = "W010" // a --transform that couldn't be applied to a value.
+	warnTruncated             warningCode = "W011" // an html file that looks cut off, munged anyway with --salvage.
+	warnJournalCommodity      warningCode = "W012" // a distribution schedule with no commodity in --journal-config.
+	warnQifSkipped            warningCode = "W013" // an event left out of a qif file.
+	warnJournalProceeds       warningCode = "W014" // a sale in a journal whose gross or net proceeds had to be worked out.
+	warnUnsortableMoney       warningCode = "W015" // a value in a --sort column of money that doesn't read as an amount.
+)
+
+var knownWarningCodes = map[warningCode]bool{
+	warnUnparseableDate:       true,
+	warnFallbackParser:        true,
+	warnUnparseableShareCount: true,
+	warnOversold:              true,
+	warnFractionalBalance:     true,
+	warnSharesOverLimit:       true,
+	warnValueOverLimit:        true,
+	warnEventDateMismatch:     true,
+	warnTimelineNoDate:        true,
+	warnTransformFailed:       true,
+	warnTruncated:             true,
+	warnJournalCommodity:      true,
+	warnQifSkipped:            true,
+	warnJournalProceeds:       true,
+	warnUnsortableMoney:       true,
+}
+
+// suppressedWarnings are the codes given to --suppress-warning.
+// Suppressed warnings still go to the log file (as trace), so they're not lost entirely; they just don't get printed.
+var suppressedWarnings = map[warningCode]bool{}
+
+// suppressedWarningCount is how many warnings were suppressed, so we can at least mention that some were.
+var suppressedWarningCount int
+
+// parseSuppressedWarnings parses a comma-separated list of warning codes, like "W004,W005".
+func parseSuppressedWarnings(s string) (map[warningCode]bool, error) {
+	codes := map[warningCode]bool{}
+	for _, part := range strings.Split(s, ",") {
+		code := warningCode(strings.ToUpper(strings.TrimSpace(part)))
+		if code == "" {
+			continue
+		}
+		if !knownWarningCodes[code] {
+			var known []string
+			for k := range knownWarningCodes {
+				known = append(known, string(k))
+			}
+			sort.Strings(known)
+			return nil, fmt.Errorf("unknown warning code %q -- known codes are: %s", code, strings.Join(known, ", "))
+		}
+		codes[code] = true
+	}
+	return codes, nil
+}